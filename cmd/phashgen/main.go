@@ -0,0 +1,169 @@
+// Command phashgen generates a perfect hash function and keyword table for
+// keyword lookup in lexers. It is meant to be run by go:generate:
+//
+//	//go:generate go run github.com/soypat/lexer/cmd/phashgen -type=Token -range=keywordBeg,keywordEnd -output=kwhash.go
+//
+// With -range the keywords are the constants of -type declared between the two
+// marker constants, the keyword text being the constant's line comment as with
+// stringer -linecomment, or the constant name if there is no comment.
+// Alternatively keywords are listed explicitly with -keywords=if:TokIf,else:TokElse.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/soypat/lexer/phash"
+)
+
+func main() {
+	if err := run(); err != nil {
+		fmt.Fprintln(os.Stderr, "phashgen:", err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	var (
+		typ      = flag.String("type", "", "type of keyword table elements (required)")
+		rng      = flag.String("range", "", "comma separated constants of -type delimiting keywords, exclusive")
+		keywords = flag.String("keywords", "", "comma separated keyword:value pairs, used instead of -range")
+		output   = flag.String("output", "kwhash.go", "output file name")
+		fn       = flag.String("func", "kwhash", "name of generated hash function")
+		table    = flag.String("table", "keywordMap", "name of generated keyword table")
+		pkg      = flag.String("pkg", "", "package name of generated file, defaults to package in current directory")
+		maxBits  = flag.Int("maxbits", 10, "maximum keyword table size in bits")
+	)
+	flag.Parse()
+	if *typ == "" {
+		flag.Usage()
+		return errors.New("-type required")
+	}
+	var kws []phash.Keyword
+	var err error
+	pkgName := *pkg
+	switch {
+	case *keywords != "" && *rng != "":
+		return errors.New("-range and -keywords are mutually exclusive")
+	case *keywords != "":
+		kws, err = parseKeywordList(*keywords)
+	case *rng != "":
+		beg, end, ok := strings.Cut(*rng, ",")
+		if !ok {
+			return errors.New("-range requires two comma separated constants")
+		}
+		var name string
+		name, kws, err = parseKeywordRange(".", *output, *typ, beg, end)
+		if pkgName == "" {
+			pkgName = name
+		}
+	default:
+		return errors.New("-range or -keywords required")
+	}
+	if err != nil {
+		return err
+	}
+	if pkgName == "" {
+		return errors.New("package name not found, use -pkg")
+	}
+	cfg := phash.GenConfig{
+		Package:      pkgName,
+		Command:      "phashgen " + strings.Join(os.Args[1:], " "),
+		Func:         *fn,
+		Table:        *table,
+		Type:         *typ,
+		MaxTableBits: *maxBits,
+	}
+	src, err := cfg.Generate(kws)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(*output, src, 0644)
+}
+
+func parseKeywordList(list string) (kws []phash.Keyword, err error) {
+	for _, pair := range strings.Split(list, ",") {
+		text, value, ok := strings.Cut(pair, ":")
+		if !ok || text == "" || value == "" {
+			return nil, fmt.Errorf("invalid keyword pair %q, want keyword:value", pair)
+		}
+		kws = append(kws, phash.Keyword{Text: text, Value: value})
+	}
+	return kws, nil
+}
+
+// parseKeywordRange parses the Go package in dir, excluding the output file, and returns the
+// constants of type typ declared between beg and end in the same const block.
+func parseKeywordRange(dir, output, typ, beg, end string) (pkgName string, kws []phash.Keyword, err error) {
+	fset := token.NewFileSet()
+	matches, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return "", nil, err
+	}
+	for _, filename := range matches {
+		if strings.HasSuffix(filename, "_test.go") || filepath.Base(filename) == filepath.Base(output) {
+			continue
+		}
+		f, err := parser.ParseFile(fset, filename, nil, parser.ParseComments)
+		if err != nil {
+			return "", nil, err
+		}
+		pkgName = f.Name.Name
+		for _, decl := range f.Decls {
+			gd, ok := decl.(*ast.GenDecl)
+			if !ok || gd.Tok != token.CONST {
+				continue
+			}
+			kws, found, err := keywordsInBlock(gd, typ, beg, end)
+			if err != nil {
+				return "", nil, fmt.Errorf("%s: %w", filename, err)
+			} else if found {
+				return pkgName, kws, nil
+			}
+		}
+	}
+	return "", nil, fmt.Errorf("const block of type %s with %s and %s not found", typ, beg, end)
+}
+
+func keywordsInBlock(gd *ast.GenDecl, typ, beg, end string) (kws []phash.Keyword, found bool, err error) {
+	blockType := ""
+	inRange := false
+	for _, spec := range gd.Specs {
+		vs := spec.(*ast.ValueSpec)
+		if id, ok := vs.Type.(*ast.Ident); ok {
+			blockType = id.Name
+		}
+		for _, name := range vs.Names {
+			switch {
+			case name.Name == beg:
+				inRange = true
+			case name.Name == end:
+				if !inRange {
+					return nil, false, fmt.Errorf("%s declared before %s", end, beg)
+				} else if blockType != typ {
+					return nil, false, fmt.Errorf("keyword range is of type %s, want %s", blockType, typ)
+				} else if len(kws) == 0 {
+					return nil, false, errors.New("no keywords in range")
+				}
+				return kws, true, nil
+			case inRange:
+				text := name.Name
+				if vs.Comment != nil {
+					text = strings.TrimSpace(vs.Comment.Text())
+				}
+				kws = append(kws, phash.Keyword{Text: text, Value: name.Name})
+			}
+		}
+	}
+	if inRange {
+		return nil, false, fmt.Errorf("%s not found after %s", end, beg)
+	}
+	return nil, false, nil
+}
@@ -0,0 +1,151 @@
+// Command pikegen generates a Go function computing a MATLAB expression, such as
+// the symbolic output of MATLAB for a state-space model. It is meant to be run by go:generate:
+//
+//	//go:generate go run github.com/soypat/lexer/cmd/pikegen -const=f -func=dynamics -output=dynamics.go
+//
+// With -const the expression is the string constant of that name declared in the
+// Go package of the current directory. Alternatively the expression is read from
+// the file given as argument, i.e: pikegen -func=dynamics model.m.
+// The generated function has signature func(X, U, out []float64), where the
+// variable names are given by -vars, and stores the elements of the expression in out.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/soypat/lexer/lexers/pike"
+	"github.com/soypat/lexer/lexers/pike/parse"
+	"github.com/soypat/lexer/lexers/pike/vm"
+)
+
+func main() {
+	if err := run(); err != nil {
+		fmt.Fprintln(os.Stderr, "pikegen:", err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	var (
+		constName = flag.String("const", "", "string constant of the package in current directory holding the expression")
+		vars      = flag.String("vars", "X,U", "comma separated names of the two vector variables")
+		output    = flag.String("output", "", "output file name, defaults to the function name with .go suffix")
+		fn        = flag.String("func", "F", "name of generated function")
+		pkg       = flag.String("pkg", "", "package name of generated file, defaults to package in current directory")
+	)
+	flag.Parse()
+	xname, uname, ok := strings.Cut(*vars, ",")
+	if !ok || xname == "" || uname == "" || strings.Contains(uname, ",") {
+		return errors.New("-vars requires two comma separated names")
+	}
+	if *output == "" {
+		*output = strings.ToLower(*fn) + ".go"
+	}
+	var (
+		filename, input string
+		err             error
+	)
+	pkgName := *pkg
+	switch {
+	case *constName != "" && flag.NArg() > 0:
+		return errors.New("-const and file argument are mutually exclusive")
+	case *constName != "":
+		var name string
+		name, filename, input, err = findConst(".", *output, *constName)
+		if pkgName == "" {
+			pkgName = name
+		}
+	case flag.NArg() == 1:
+		filename = flag.Arg(0)
+		var b []byte
+		b, err = os.ReadFile(filename)
+		input = strings.TrimSpace(string(b))
+	default:
+		flag.Usage()
+		return errors.New("-const or a single file argument required")
+	}
+	if err != nil {
+		return err
+	}
+	if pkgName == "" {
+		return errors.New("package name not found, use -pkg")
+	}
+	l, err := pike.NewLexer(filename, input, pike.Options{
+		Variables: []string{xname, uname},
+		Builtins:  true,
+	})
+	if err != nil {
+		return err
+	}
+	x, err := parse.Parse(l.Items())
+	if err != nil {
+		return err
+	}
+	prog, err := vm.Compile(x, xname, uname)
+	if err != nil {
+		return fmt.Errorf("%s: %w", filename, err)
+	}
+	cfg := vm.GenConfig{
+		Package: pkgName,
+		Command: "pikegen " + strings.Join(os.Args[1:], " "),
+		Func:    *fn,
+	}
+	src, err := cfg.Generate(prog)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(*output, src, 0644)
+}
+
+// findConst parses the Go package in dir, excluding the output file, and returns
+// the value of the string constant name and the file declaring it.
+func findConst(dir, output, name string) (pkgName, filename, value string, err error) {
+	fset := token.NewFileSet()
+	matches, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return "", "", "", err
+	}
+	for _, filename := range matches {
+		if strings.HasSuffix(filename, "_test.go") || filepath.Base(filename) == filepath.Base(output) {
+			continue
+		}
+		f, err := parser.ParseFile(fset, filename, nil, 0)
+		if err != nil {
+			return "", "", "", err
+		}
+		pkgName = f.Name.Name
+		for _, decl := range f.Decls {
+			gd, ok := decl.(*ast.GenDecl)
+			if !ok || gd.Tok != token.CONST {
+				continue
+			}
+			for _, spec := range gd.Specs {
+				vs := spec.(*ast.ValueSpec)
+				for i, id := range vs.Names {
+					if id.Name != name {
+						continue
+					}
+					var lit *ast.BasicLit
+					if i < len(vs.Values) {
+						lit, _ = vs.Values[i].(*ast.BasicLit)
+					}
+					if lit == nil || lit.Kind != token.STRING {
+						return "", "", "", fmt.Errorf("%s: constant %s is not a string literal", filename, name)
+					}
+					value, err = strconv.Unquote(lit.Value)
+					return pkgName, filename, value, err
+				}
+			}
+		}
+	}
+	return "", "", "", fmt.Errorf("string constant %s not found", name)
+}
@@ -0,0 +1,267 @@
+// Package diag renders compiler-style diagnostics: a "source:line:col: message"
+// header followed by the offending source line and a caret underline.
+//
+//	main.pato:3:9: illegal character U+0023 '#'
+//	x := a + #b
+//	         ^
+//
+// Diagnostics are positioned with byte offsets so that any lexer can use them.
+// Tabs are expanded and East Asian wide runes take two terminal cells
+// so that the underline stays aligned with the source line.
+package diag
+
+import (
+	"io"
+	"sort"
+	"strconv"
+	"unicode/utf8"
+)
+
+// Source is a named source text that diagnostics point into.
+type Source struct {
+	name  string
+	base  int
+	text  []byte
+	lines []int // Offsets of line starts. lines[0] is always 0.
+}
+
+// NewSource returns the source of text named name. base is the position of the
+// first byte of text, which is 0 unless positions are shared between several
+// sources as in a pato.FileSet. The Source keeps a reference to text.
+func NewSource(name string, base int, text []byte) *Source {
+	src := &Source{name: name, base: base, text: text, lines: []int{0}}
+	for off, c := range text {
+		if c == '\n' {
+			src.lines = append(src.lines, off+1)
+		}
+	}
+	return src
+}
+
+// Name returns the name of the source.
+func (src *Source) Name() string { return src.name }
+
+// Base returns the position of the first byte of the source.
+func (src *Source) Base() int { return src.base }
+
+// Contains reports whether pos is a position in the source. The position
+// immediately after the last byte, the EOF, is contained.
+func (src *Source) Contains(pos int) bool {
+	off := pos - src.base
+	return off >= 0 && off <= len(src.text)
+}
+
+// LineCol returns the 1-based line and column of pos with columns counted in runes.
+// It returns zeros if pos is not in the source.
+func (src *Source) LineCol(pos int) (line, col int) {
+	if !src.Contains(pos) {
+		return 0, 0
+	}
+	off := pos - src.base
+	line = sort.SearchInts(src.lines, off+1)
+	return line, 1 + utf8.RuneCount(src.text[src.lines[line-1]:off])
+}
+
+// lineBounds returns the offsets of the start and end of line, excluding the line terminator.
+func (src *Source) lineBounds(line int) (start, end int) {
+	start = src.lines[line-1]
+	end = len(src.text)
+	if line < len(src.lines) {
+		end = src.lines[line] - 1 // Exclude newline.
+	}
+	if end > start && src.text[end-1] == '\r' {
+		end--
+	}
+	return start, end
+}
+
+// Span is a range of positions [Start, End). A Span with End <= Start
+// marks the single character at Start.
+type Span struct {
+	Start, End int
+}
+
+// Note is a secondary message attached to a [Diagnostic], optionally pointing into the source.
+type Note struct {
+	Span Span
+	Msg  string
+}
+
+// Diagnostic is a message about a span of a [Source].
+type Diagnostic struct {
+	Span  Span
+	Msg   string
+	Notes []Note // Rendered after the diagnostic, each with its own snippet if its span is in the source.
+}
+
+// Printer renders diagnostics. The zero value is ready to use and renders without color.
+type Printer struct {
+	// Color enables ANSI terminal colors.
+	Color bool
+	// TabWidth is the distance between tab stops. If zero a width of 4 is used.
+	TabWidth int
+	// MaxLineWidth limits the cells of a source line printed. Longer lines are cut
+	// around the underline and elided with "...". If zero lines are never cut.
+	MaxLineWidth int
+}
+
+const (
+	ansiReset = "\x1b[0m"
+	ansiBold  = "\x1b[1m"
+	ansiRed   = "\x1b[1;31m"
+	ansiCyan  = "\x1b[1;36m"
+)
+
+// Format returns a diagnostic for span of src rendered by the zero Printer.
+func Format(src *Source, span Span, msg string) string {
+	var p Printer
+	return string(p.Append(nil, src, &Diagnostic{Span: span, Msg: msg}))
+}
+
+// Fprint writes the rendered diagnostic to w.
+func (p *Printer) Fprint(w io.Writer, src *Source, d *Diagnostic) error {
+	_, err := w.Write(p.Append(nil, src, d))
+	return err
+}
+
+// Append appends the rendered diagnostic to dst and returns the result.
+// Every rendered line, including the last, ends in a newline.
+func (p *Printer) Append(dst []byte, src *Source, d *Diagnostic) []byte {
+	dst = p.appendOne(dst, src, d.Span, "", d.Msg, ansiRed)
+	for _, note := range d.Notes {
+		dst = p.appendOne(dst, src, note.Span, "note: ", note.Msg, ansiCyan)
+	}
+	return dst
+}
+
+func (p *Printer) appendOne(dst []byte, src *Source, span Span, label, msg, color string) []byte {
+	line, col := src.LineCol(span.Start)
+	dst = p.appendColor(dst, ansiBold)
+	dst = append(dst, src.name...)
+	if line > 0 {
+		dst = append(dst, ':')
+		dst = strconv.AppendInt(dst, int64(line), 10)
+		dst = append(dst, ':')
+		dst = strconv.AppendInt(dst, int64(col), 10)
+	}
+	dst = append(dst, ':')
+	dst = p.appendColor(dst, ansiReset)
+	dst = append(dst, ' ')
+	if label != "" {
+		dst = p.appendColor(dst, color)
+		dst = append(dst, label...)
+		dst = p.appendColor(dst, ansiReset)
+	}
+	dst = append(dst, msg...)
+	dst = append(dst, '\n')
+	if line == 0 {
+		return dst
+	}
+	return p.appendSnippet(dst, src, line, span, color)
+}
+
+// cell is a rune of a source line as displayed on a terminal.
+type cell struct {
+	off   int    // Byte offset in source text.
+	col   int    // Display column at which cell starts, 0-based.
+	width int    // Display width in columns.
+	text  string // Text displayed.
+}
+
+func (p *Printer) appendSnippet(dst []byte, src *Source, line int, span Span, color string) []byte {
+	start, end := src.lineBounds(line)
+	tabw := p.TabWidth
+	if tabw <= 0 {
+		tabw = 4
+	}
+	var cells []cell
+	col := 0
+	for off := start; off < end; {
+		r, sz := utf8.DecodeRune(src.text[off:end])
+		c := cell{off: off, col: col}
+		switch {
+		case r == '\t':
+			c.width = tabw - col%tabw
+			c.text = spaces(c.width)
+		case r < ' ' || r == 0x7f:
+			c.width, c.text = 1, " " // Control characters would garble terminal.
+		case r == utf8.RuneError && sz == 1:
+			c.width, c.text = 1, "�"
+		default:
+			c.width, c.text = RuneWidth(r), string(src.text[off:off+sz])
+		}
+		cells = append(cells, c)
+		col += c.width
+		off += sz
+	}
+	// Underline columns [ulStart, ulEnd) clipped to the line. A span past the
+	// end of the line, i.e: at the newline or EOF, underlines the cell after it.
+	startOff := span.Start - src.base
+	endOff := min(span.End-src.base, end)
+	ulStart, ulEnd := col, col+1
+	for _, c := range cells {
+		if c.off < startOff {
+			continue
+		}
+		if c.off == startOff {
+			ulStart, ulEnd = c.col, c.col+max(c.width, 1)
+		} else if c.off < endOff {
+			ulEnd = c.col + c.width
+		}
+	}
+	// Window of displayed columns [winStart, winEnd).
+	winStart, winEnd := 0, col
+	if p.MaxLineWidth > 0 && col > p.MaxLineWidth {
+		winStart = max(0, min(ulStart-p.MaxLineWidth/3, col-p.MaxLineWidth))
+		winEnd = winStart + p.MaxLineWidth
+	}
+	const ellipsis = "..."
+	indent := 0
+	if winStart > 0 {
+		dst = append(dst, ellipsis...)
+		indent = len(ellipsis)
+	}
+	for _, c := range cells {
+		if c.col >= winStart && c.col+c.width <= winEnd {
+			dst = append(dst, c.text...)
+		} else if c.col < winStart && c.col+c.width > winStart {
+			dst = append(dst, spaces(c.col+c.width-winStart)...) // Partially visible tab or wide rune.
+		}
+	}
+	if winEnd < col {
+		dst = append(dst, ellipsis...)
+	}
+	dst = append(dst, '\n')
+
+	ulStart = max(ulStart, winStart)
+	ulEnd = min(ulEnd, max(winEnd, ulStart+1))
+	dst = append(dst, spaces(indent+ulStart-winStart)...)
+	dst = p.appendColor(dst, color)
+	dst = append(dst, '^')
+	for range ulEnd - ulStart - 1 {
+		dst = append(dst, '~')
+	}
+	dst = p.appendColor(dst, ansiReset)
+	dst = append(dst, '\n')
+	return dst
+}
+
+func (p *Printer) appendColor(dst []byte, code string) []byte {
+	if !p.Color {
+		return dst
+	}
+	return append(dst, code...)
+}
+
+const blanks = "                                                                "
+
+func spaces(n int) string {
+	if n <= len(blanks) {
+		return blanks[:n]
+	}
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = ' '
+	}
+	return string(b)
+}
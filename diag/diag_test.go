@@ -0,0 +1,93 @@
+package diag
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPrinter(t *testing.T) {
+	const text = "x := 1\n\ty = a + #b\n変数 := ?\nlast"
+	src := NewSource("main.pato", 0, []byte(text))
+	pos := func(s string) int { return strings.Index(text, s) }
+	tests := []struct {
+		name string
+		p    Printer
+		d    Diagnostic
+		want string
+	}{
+		{
+			name: "single char",
+			d:    Diagnostic{Span: Span{Start: pos("#")}, Msg: "illegal character"},
+			want: "main.pato:2:10: illegal character\n    y = a + #b\n            ^\n",
+		},
+		{
+			name: "span and tab width",
+			p:    Printer{TabWidth: 8},
+			d:    Diagnostic{Span: Span{Start: pos("a"), End: pos("#b") + 2}, Msg: "bad"},
+			want: "main.pato:2:6: bad\n        y = a + #b\n            ^~~~~~\n",
+		},
+		{
+			name: "wide runes",
+			d:    Diagnostic{Span: Span{Start: pos("?"), End: pos("?") + 1}, Msg: "unexpected"},
+			want: "main.pato:3:7: unexpected\n変数 := ?\n        ^\n",
+		},
+		{
+			name: "wide rune underline",
+			d:    Diagnostic{Span: Span{Start: pos("数"), End: pos(" :=")}, Msg: "m"},
+			want: "main.pato:3:2: m\n変数 := ?\n  ^~\n",
+		},
+		{
+			name: "end of line and EOF",
+			d: Diagnostic{Span: Span{Start: pos("\n")}, Msg: "missing semicolon", Notes: []Note{
+				{Span: Span{Start: len(text)}, Msg: "file ends here"},
+				{Span: Span{Start: -1}, Msg: "no position"},
+			}},
+			want: "main.pato:1:7: missing semicolon\nx := 1\n      ^\n" +
+				"main.pato:4:5: note: file ends here\nlast\n    ^\n" +
+				"main.pato: note: no position\n",
+		},
+		{
+			name: "multiline span clipped",
+			d:    Diagnostic{Span: Span{Start: pos("1"), End: pos("y")}, Msg: "m"},
+			want: "main.pato:1:6: m\nx := 1\n     ^\n",
+		},
+		{
+			name: "color",
+			p:    Printer{Color: true},
+			d:    Diagnostic{Span: Span{Start: 0, End: 1}, Msg: "m", Notes: []Note{{Span: Span{Start: 2}, Msg: "n"}}},
+			want: "\x1b[1mmain.pato:1:1:\x1b[0m m\nx := 1\n\x1b[1;31m^\x1b[0m\n" +
+				"\x1b[1mmain.pato:1:3:\x1b[0m \x1b[1;36mnote: \x1b[0mn\nx := 1\n  \x1b[1;36m^\x1b[0m\n",
+		},
+	}
+	for _, test := range tests {
+		got := string(test.p.Append(nil, src, &test.d))
+		if got != test.want {
+			t.Errorf("%s: got\n%q\nwant\n%q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestPrinterMaxLineWidth(t *testing.T) {
+	text := strings.Repeat("a+", 50) + "#" + strings.Repeat("+b", 50)
+	src := NewSource("long", 100, []byte(text))
+	p := Printer{MaxLineWidth: 30}
+	got := string(p.Append(nil, src, &Diagnostic{Span: Span{Start: 100 + 100}, Msg: "m"}))
+	want := "long:1:101: m\n..." + text[90:120] + "...\n" + strings.Repeat(" ", 3+10) + "^\n"
+	if got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestRuneWidth(t *testing.T) {
+	for _, test := range []struct {
+		r    rune
+		want int
+	}{
+		{'a', 1}, {'é', 1}, {'\u0301', 0}, {'\u200d', 0}, {'変', 2}, {'한', 2},
+		{'Ａ', 2}, {'😀', 2}, {'Δ', 1}, {'→', 1},
+	} {
+		if got := RuneWidth(test.r); got != test.want {
+			t.Errorf("RuneWidth(%q) = %d, want %d", test.r, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package diag
+
+import "unicode"
+
+// RuneWidth returns the number of terminal cells taken by r: 0 for combining
+// marks and format characters, 2 for East Asian wide and fullwidth characters
+// and emoji, and 1 otherwise. Tabs and control characters are not handled.
+func RuneWidth(r rune) int {
+	switch {
+	case r < 0x300:
+		return 1 // Fast path for Latin.
+	case unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf):
+		return 0
+	case unicode.Is(wide, r):
+		return 2
+	}
+	return 1
+}
+
+// wide holds the East Asian Wide (W) and Fullwidth (F) ranges of Unicode UAX #11,
+// merged where contiguous and excluding unassigned blocks.
+var wide = &unicode.RangeTable{
+	R16: []unicode.Range16{
+		{0x1100, 0x115f, 1}, // Hangul Jamo initial consonants.
+		{0x231a, 0x231b, 1}, // Watch, hourglass.
+		{0x2329, 0x232a, 1}, // Angle brackets.
+		{0x23e9, 0x23ec, 1}, // Media control symbols.
+		{0x23f0, 0x23f0, 1}, // Alarm clock.
+		{0x23f3, 0x23f3, 1}, // Hourglass with flowing sand.
+		{0x25fd, 0x25fe, 1}, // Medium small squares.
+		{0x2614, 0x2615, 1}, // Umbrella, hot beverage.
+		{0x2648, 0x2653, 1}, // Zodiac.
+		{0x267f, 0x267f, 1}, // Wheelchair.
+		{0x2693, 0x2693, 1}, // Anchor.
+		{0x26a1, 0x26a1, 1}, // High voltage.
+		{0x26aa, 0x26ab, 1}, // Medium circles.
+		{0x26bd, 0x26be, 1}, // Soccer ball, baseball.
+		{0x26c4, 0x26c5, 1}, // Snowman, sun behind cloud.
+		{0x26ce, 0x26ce, 1}, // Ophiuchus.
+		{0x26d4, 0x26d4, 1}, // No entry.
+		{0x26ea, 0x26ea, 1}, // Church.
+		{0x26f2, 0x26f3, 1}, // Fountain, golf flag.
+		{0x26f5, 0x26f5, 1}, // Sailboat.
+		{0x26fa, 0x26fa, 1}, // Tent.
+		{0x26fd, 0x26fd, 1}, // Fuel pump.
+		{0x2705, 0x2705, 1}, // Check mark.
+		{0x270a, 0x270b, 1}, // Raised fists.
+		{0x2728, 0x2728, 1}, // Sparkles.
+		{0x274c, 0x274c, 1}, // Cross mark.
+		{0x274e, 0x274e, 1}, // Negative squared cross mark.
+		{0x2753, 0x2755, 1}, // Question and exclamation marks.
+		{0x2757, 0x2757, 1}, // Heavy exclamation mark.
+		{0x2795, 0x2797, 1}, // Heavy plus, minus, division.
+		{0x27b0, 0x27b0, 1}, // Curly loop.
+		{0x27bf, 0x27bf, 1}, // Double curly loop.
+		{0x2b1b, 0x2b1c, 1}, // Large squares.
+		{0x2b50, 0x2b50, 1}, // White medium star.
+		{0x2b55, 0x2b55, 1}, // Heavy large circle.
+		{0x2e80, 0x303e, 1}, // CJK radicals, Kangxi, ideographic description, CJK symbols.
+		{0x3041, 0x33ff, 1}, // Hiragana, Katakana, Bopomofo, Hangul compatibility, Kanbun, CJK strokes, enclosed, compatibility.
+		{0x3400, 0x4dbf, 1}, // CJK extension A.
+		{0x4e00, 0xa4cf, 1}, // CJK unified ideographs, Yi.
+		{0xa960, 0xa97f, 1}, // Hangul Jamo extended A.
+		{0xac00, 0xd7a3, 1}, // Hangul syllables.
+		{0xf900, 0xfaff, 1}, // CJK compatibility ideographs.
+		{0xfe10, 0xfe19, 1}, // Vertical forms.
+		{0xfe30, 0xfe6f, 1}, // CJK compatibility forms, small form variants.
+		{0xff00, 0xff60, 1}, // Fullwidth forms.
+		{0xffe0, 0xffe6, 1}, // Fullwidth signs.
+	},
+	R32: []unicode.Range32{
+		{0x16fe0, 0x18cff, 1}, // Tangut, Khitan.
+		{0x1b000, 0x1b2ff, 1}, // Kana supplement and extensions, Nushu.
+		{0x1f004, 0x1f004, 1}, // Mahjong red dragon.
+		{0x1f0cf, 0x1f0cf, 1}, // Playing card black joker.
+		{0x1f18e, 0x1f18e, 1}, // Negative squared AB.
+		{0x1f191, 0x1f19a, 1}, // Squared CL through VS.
+		{0x1f200, 0x1f2ff, 1}, // Enclosed ideographic supplement.
+		{0x1f300, 0x1f320, 1}, // Weather and landscape emoji.
+		{0x1f32d, 0x1f335, 1},
+		{0x1f337, 0x1f37c, 1},
+		{0x1f37e, 0x1f393, 1},
+		{0x1f3a0, 0x1f3ca, 1},
+		{0x1f3cf, 0x1f3d3, 1},
+		{0x1f3e0, 0x1f3f0, 1},
+		{0x1f3f4, 0x1f3f4, 1},
+		{0x1f3f8, 0x1f43e, 1},
+		{0x1f440, 0x1f440, 1},
+		{0x1f442, 0x1f4fc, 1},
+		{0x1f4ff, 0x1f53d, 1},
+		{0x1f54b, 0x1f54e, 1},
+		{0x1f550, 0x1f567, 1},
+		{0x1f57a, 0x1f57a, 1},
+		{0x1f595, 0x1f596, 1},
+		{0x1f5a4, 0x1f5a4, 1},
+		{0x1f5fb, 0x1f64f, 1}, // Emoticons.
+		{0x1f680, 0x1f6c5, 1}, // Transport and map symbols.
+		{0x1f6cc, 0x1f6cc, 1},
+		{0x1f6d0, 0x1f6d2, 1},
+		{0x1f6d5, 0x1f6d7, 1},
+		{0x1f6dc, 0x1f6df, 1},
+		{0x1f6eb, 0x1f6ec, 1},
+		{0x1f6f4, 0x1f6fc, 1},
+		{0x1f7e0, 0x1f7eb, 1},
+		{0x1f7f0, 0x1f7f0, 1},
+		{0x1f90c, 0x1f93a, 1}, // Supplemental symbols and pictographs.
+		{0x1f93c, 0x1f945, 1},
+		{0x1f947, 0x1f9ff, 1},
+		{0x1fa70, 0x1faff, 1}, // Symbols and pictographs extended A.
+		{0x20000, 0x2fffd, 1}, // CJK extensions B through F, compatibility supplement.
+		{0x30000, 0x3fffd, 1}, // CJK extensions G and H.
+	},
+}
@@ -0,0 +1,66 @@
+// Code generated by "pikegen -const=f -func=dynamics -output=dynamics.go"; DO NOT EDIT.
+
+package main
+
+import "math"
+
+// dynamics stores the elements of the 12x1 value of the expression in out in column-major order.
+func dynamics(X, U, out []float64) {
+	_ = X[11]   // Bounds check hint.
+	_ = U[3]    // Bounds check hint.
+	_ = out[11] // Bounds check hint.
+	out[0] = X[6]
+	out[1] = X[7]
+	out[2] = X[8]
+	out[3] = X[9]
+	out[4] = X[10]
+	out[5] = X[11]
+	t0 := math.Sin(U[1])
+	t1 := U[0] * t0
+	t2 := math.Cos(X[3])
+	t3 := math.Sin(X[5])
+	t4 := math.Cos(X[5])
+	t5 := math.Sin(X[3])
+	t6 := t4 * t5
+	t7 := math.Sin(X[4])
+	t8 := t2*t3 - t6*t7
+	t9 := U[0] * math.Cos(U[1]+U[2])
+	t10 := t2 * t4
+	t11 := t3*t5 + t7*t10
+	t12 := math.Cos(X[4])
+	t13 := U[0] * t12
+	t14 := math.Sin(U[2])
+	out[6] = t1*t8/4 + t9*t11/4 + t4*t13*t14/4
+	t15 := t6 - t3*(t2*t7)
+	t16 := t10 + t3*(t5*t7)
+	out[7] = t14*(t3*t13)/4 - t9*t15/4 - t1*t16/4
+	out[8] = t12*(t2*t9)/4 - t14*(U[0]*t7)/4 - t0*(t5*t13)/4 - 9.79
+	t17 := U[0] * 3
+	t18 := t2 * (39271 * (X[11] * X[11]))
+	t19 := 39271 * (X[10] * X[10])
+	t20 := t2 * t19
+	t21 := t3 * t3
+	t22 := X[9] * 39271
+	t23 := X[11] * t22
+	t24 := t2 * t23
+	t25 := t12 * t12
+	t26 := X[10] * t22
+	t27 := t12 * t26
+	t28 := t4 * t4
+	t29 := t7 * t7
+	t30 := X[11] * (X[10] * 39271)
+	t31 := t2 * t30
+	t32 := 39271 * (X[9] * X[9])
+	t33 := t2 * t32
+	t34 := t2 * t26
+	t35 := t14*t17/20 - t7*(t12*t18)/40000 + t7*(t12*t20)*t21/40000 + t4*(t24*t25)/40000 - t5*(t27*t28)/40000 - t4*t24*t29/40000 + t3*(t25*t31)/40000 + t21*(t5*t27)/40000 - t3*(t29*t31)/40000 + t3*(t5*(t4*(t12*t32)))/40000 - t3*(t5*(t4*(t12*t19)))/40000 + t7*(t5*(t4*t30))/40000 - t3*(t7*(t5*t23))/40000 + t7*(t28*(t12*t33))/40000 + t3*(t7*(t4*(t12*t34)))/20000
+	t36 := t2 * t2
+	t37 := t5 * t5
+	t38 := t26 * t36
+	t39 := t5 * (t4 * t34)
+	t40 := t5*(t18*t25)/40000 - t5*(t20*t28)/40000 - t0*t17/20 - t21*(t5*t33)/40000 - t3*(t7*(t4*(t32*t36)))/40000 + t3*(t7*(t4*(t19*t36)))/40000 + t3*(t7*(t4*t32*t37))/40000 - t3*(t7*(t37*(t4*t19)))/40000 + t4*(t12*(t30*t36))/40000 - t3*(t12*(t23*t36))/40000 - t37*(t4*(t12*t30))/40000 + t3*(t37*(t12*t23))/40000 + t29*(t5*(t28*t33))/40000 + t21*(t29*(t5*t20))/40000 + t7*(t28*t38)/40000 - t21*(t7*t38)/40000 - t7*(t37*(t26*t28))/40000 + t21*(t7*(t26*t37))/40000 + t3*t39/20000 + t3*(t29*t39)/20000 + t7*(t5*(t4*(t12*t24)))/20000 + t3*(t7*(t5*(t12*t31)))/20000
+	t41 := U[0] * 800 * U[3]
+	out[9] = t8*t35 + t4*t12*t40 + t11*t41/243
+	out[10] = t40*(t3*t12) - t16*t35 - t15*t41/243
+	out[11] = t12*(t2*t41)/243 - t35*(t5*t12) - t7*t40
+}
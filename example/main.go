@@ -1,5 +1,7 @@
 package main
 
+//go:generate go run ../cmd/pikegen -const=f -func=dynamics -output=dynamics.go
+
 import (
 	"fmt"
 	"os"
@@ -21,20 +23,18 @@ func main() {
 
 // actual program
 func run() error {
-	lexy := lex.NewStringLexer("matlabfunc.m", f)
-	// add identifiers
-	var variables = []string{"X", "U"}
-	var functions = []string{"sin", "cos"}
-	for _, v := range variables {
-		_ = lexy.NewVariableID(v)
-	}
-	for _, v := range functions {
-		_ = lexy.NewFunctionID(v)
+	lexy, err := lex.NewLexer("matlabfunc.m", f, lex.Options{
+		Variables: []string{"X", "U"},
+		Builtins:  true,
+	})
+	if err != nil {
+		return err
 	}
 	// lexer pushes item tokens to channel. parse picks em up
 	parser := parse(lexy.ItemChannel())
 	go lexy.Run()
 	parser.run()
+	lexy.Close() // Parser may stop early on error, unblock lexer.
 
 	fo, err := os.Create("output.txt")
 	if err != nil {
@@ -0,0 +1,121 @@
+package pato
+
+import (
+	"errors"
+	"fmt"
+	"unicode/utf8"
+)
+
+// CommentSyntax configures the comments recognized by the [Lexer], see [Spec].
+// The zero value recognizes no comments.
+type CommentSyntax struct {
+	// Line lists prefixes of comments that run until the end of the line, i.e: "//", "#" or "--".
+	// The terminating newline is not part of the comment.
+	Line []string
+	// Block lists the delimiters of comments that may span several lines, i.e: /* */.
+	Block []BlockComment
+	// Nested makes block comments nest so that "/* a /* b */ c */" is a single comment.
+	Nested bool
+}
+
+// BlockComment holds the opening and closing delimiters of a block comment.
+type BlockComment struct {
+	Open, Close string
+}
+
+// Common comment syntaxes.
+var (
+	CommentsC     = CommentSyntax{Line: []string{"//"}, Block: []BlockComment{{Open: "/*", Close: "*/"}}}
+	CommentsShell = CommentSyntax{Line: []string{"#"}}
+	CommentsSQL   = CommentSyntax{Line: []string{"--"}, Block: []BlockComment{{Open: "/*", Close: "*/"}}}
+)
+
+func (cs *CommentSyntax) validate() error {
+	for _, delim := range cs.Line {
+		if err := validateDelim(delim); err != nil {
+			return err
+		}
+	}
+	for _, block := range cs.Block {
+		if err := validateDelim(block.Open); err != nil {
+			return err
+		}
+		if err := validateDelim(block.Close); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateDelim(delim string) error {
+	if delim == "" {
+		return errors.New("empty comment delimiter")
+	} else if n := utf8.RuneCountInString(delim); n > peeklen+1 {
+		return fmt.Errorf("comment delimiter %q exceeds lexer lookahead of %d characters", delim, peeklen+1)
+	}
+	return nil
+}
+
+// readComment reads the comment starting at the current character, if any.
+// It returns TokComment after reading a comment, TokIllegal if a block comment
+// is not terminated and TokUndefined if no comment starts at the current character.
+// The comment text, delimiters included, is only stored in literal if KeepComments is set.
+func (l *Lexer) readComment() (Token, []byte) {
+	cs := &l.spec.comments
+	for _, prefix := range cs.Line {
+		if !l.hasPrefix(prefix) {
+			continue
+		}
+		start := l.bufstart()
+		for l.ch != '\n' && !l.IsDone() {
+			l.commentAdvance()
+		}
+		return TokComment, l.idbuf[start:]
+	}
+	for _, block := range cs.Block {
+		if !l.hasPrefix(block.Open) {
+			continue
+		}
+		start := l.bufstart()
+		pos, lc := l.Pos(), l.LineCol()
+		l.commentSkip(block.Open)
+		depth := 1
+		for depth > 0 {
+			switch {
+			case l.IsDone():
+				offending := l.idbuf[start:]
+				if !l.KeepComments {
+					offending = []byte(block.Open) // Comment text was discarded.
+				}
+				l.errorf(CodeUnterminatedComment, pos, lc, offending, "comment not terminated")
+				return TokIllegal, l.idbuf[start:]
+			case l.hasPrefix(block.Close):
+				l.commentSkip(block.Close)
+				depth--
+			case cs.Nested && l.hasPrefix(block.Open):
+				l.commentSkip(block.Open)
+				depth++
+			default:
+				l.commentAdvance()
+			}
+		}
+		return TokComment, l.idbuf[start:]
+	}
+	return TokUndefined, nil
+}
+
+// commentAdvance advances the lexer, keeping the current character only if comments are kept.
+func (l *Lexer) commentAdvance() {
+	if l.KeepComments {
+		l.consume()
+	} else {
+		l.advance()
+	}
+}
+
+// commentSkip advances over the comment delimiter at the current position.
+func (l *Lexer) commentSkip(delim string) {
+	for range utf8.RuneCountInString(delim) {
+		l.commentAdvance()
+	}
+}
@@ -0,0 +1,45 @@
+package pato
+
+import "github.com/soypat/lexer/diag"
+
+// ErrorCode classifies the reason of a lexing [Error].
+type ErrorCode uint8
+
+const (
+	CodeUndefined           ErrorCode = iota // undefined
+	CodeIllegalChar                          // illegal character
+	CodeBadNumber                            // malformed number literal
+	CodeUnterminatedLiteral                  // unterminated literal
+	CodeBadEscape                            // invalid escape sequence
+	CodeBadCharLiteral                       // invalid char literal
+	CodeUnterminatedComment                  // unterminated comment
+)
+
+// Error is a lexing error positioned in the source.
+type Error struct {
+	Pos     Pos       // Byte offset of the start of the offending input.
+	LineCol LineCol   // Line and column of the start of the offending input.
+	Bytes   []byte    // Offending input.
+	Code    ErrorCode // Reason for the error.
+	Msg     string    // Detailed description of the error.
+}
+
+// Error returns the error formatted as "source:line:col: message".
+func (e *Error) Error() string {
+	b := e.LineCol.AppendString(nil)
+	b = append(b, ": "...)
+	b = append(b, e.Msg...)
+	return string(b)
+}
+
+// Diagnostic returns the error as a diagnostic underlining the offending input.
+// Render it with the source of the lexed input, see [LineTable.DiagSource] and [File.DiagSource].
+func (e *Error) Diagnostic() *diag.Diagnostic {
+	return &diag.Diagnostic{
+		Span: diag.Span{Start: int(e.Pos), End: int(e.Pos) + len(e.Bytes)},
+		Msg:  e.Msg,
+	}
+}
+
+// ErrorHandler is called by the [Lexer] for every error encountered.
+type ErrorHandler func(err *Error)
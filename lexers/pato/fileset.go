@@ -0,0 +1,117 @@
+package pato
+
+import (
+	"errors"
+	"io"
+	"sort"
+	"sync"
+
+	"github.com/soypat/lexer/diag"
+)
+
+// NoPos is the zero Pos. It is never a valid position in a [FileSet].
+const NoPos Pos = 0
+
+// FileSet is a set of source files sharing a single position space, so that a
+// Pos identifies both a file and a byte offset in it. Each file is assigned a
+// base and its positions are base+offset. The methods of a FileSet may be called
+// concurrently, but a file must not be looked up while it is being lexed since
+// its line table is recorded during lexing.
+type FileSet struct {
+	mu    sync.RWMutex
+	base  int
+	files []*File
+}
+
+// File is a source file of a [FileSet].
+type File struct {
+	name  string
+	base  int
+	size  int
+	lines LineTable
+}
+
+// AddFile adds a file of the given name and size in bytes to the set and returns it.
+// Its positions range from Base to Base+size inclusive, the last one being its EOF.
+// The file's line table is recorded when it is lexed with [Lexer.ResetFile].
+func (fs *FileSet) AddFile(name string, size int) *File {
+	if size < 0 {
+		panic("negative file size")
+	}
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if fs.base == 0 {
+		fs.base = 1 // Base starts at 1 so that NoPos is invalid.
+	}
+	f := &File{name: name, base: fs.base, size: size}
+	f.lines.reset(name)
+	fs.base += size + 1 // +1 so that EOF position is unique to file.
+	fs.files = append(fs.files, f)
+	return f
+}
+
+// File returns the file containing pos or nil if there is none.
+func (fs *FileSet) File(pos Pos) *File {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+	// Find first file starting after pos, pos is in the file before it.
+	i := sort.Search(len(fs.files), func(i int) bool { return fs.files[i].base > int(pos) })
+	if i == 0 {
+		return nil
+	}
+	f := fs.files[i-1]
+	if int(pos) > f.base+f.size {
+		return nil
+	}
+	return f
+}
+
+// LineCol returns the file name, line and column of pos, with columns counted in runes.
+// It returns the zero LineCol if pos is not in a file of the set.
+func (fs *FileSet) LineCol(pos Pos) LineCol {
+	f := fs.File(pos)
+	if f == nil {
+		return LineCol{}
+	}
+	return f.LineCol(pos, ColumnRunes)
+}
+
+// Name returns the name of the file.
+func (f *File) Name() string { return f.name }
+
+// Base returns the position of the first byte of the file.
+func (f *File) Base() Pos { return Pos(f.base) }
+
+// Size returns the size of the file in bytes.
+func (f *File) Size() int { return f.size }
+
+// Pos returns the position of the byte at offset in the file.
+func (f *File) Pos(offset int) Pos { return Pos(f.base + offset) }
+
+// Offset returns the byte offset of pos in the file.
+func (f *File) Offset(pos Pos) int { return int(pos) - f.base }
+
+// Lines returns the line table of the file. It is empty until the file is lexed.
+func (f *File) Lines() *LineTable { return &f.lines }
+
+// DiagSource returns the source of the file for rendering diagnostics of positions in the file.
+func (f *File) DiagSource() *diag.Source {
+	return diag.NewSource(f.name, f.base, f.lines.src)
+}
+
+// LineCol returns the line and column of pos in the file with columns counted in unit.
+func (f *File) LineCol(pos Pos, unit ColumnUnit) LineCol {
+	return f.lines.LineCol(Pos(f.Offset(pos)), unit)
+}
+
+// ResetFile initializes the lexer to read the contents of f from r, as Reset does.
+// Positions returned by the lexer are positions in f's FileSet and the file's
+// line table is recorded during lexing. Lines is not used while lexing a file.
+// Reading more than the file's size from r is an error: NextToken returns
+// TokIllegal once the file's size is reached and Err reports the error.
+func (l *Lexer) ResetFile(f *File, r io.Reader) error {
+	if f == nil {
+		return errors.New("nil file")
+	}
+	return l.reset(f.name, r, &f.lines, f.base, f.size)
+}
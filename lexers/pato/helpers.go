@@ -1,7 +1,7 @@
 package pato
 
 func (l *Lexer) skipWhitespace() {
-	for isWhitespace(l.ch) {
+	for isWhitespace(l.ch) || l.ch == '\n' && !l.spec.newline {
 		l.advance()
 	}
 }
@@ -14,6 +14,13 @@ func isDigit(ch rune) bool {
 	return '0' <= ch && ch <= '9'
 }
 
+func isHex(ch rune) bool {
+	return isDigit(ch) || 'a' <= lower(ch) && lower(ch) <= 'f'
+}
+
+// lower returns the lowercase version of an ASCII letter.
+func lower(ch rune) rune { return ('a' - 'A') | ch }
+
 func isDigitOrDecimal(ch rune) bool {
 	return ch == '.' || isDigit(ch)
 }
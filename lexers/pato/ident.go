@@ -0,0 +1,78 @@
+package pato
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// Identifier classification per Unicode Standard Annex #31.
+// XID_Start and XID_Continue are derived from the general categories and
+// properties in the unicode package, removing the characters whose NFKC
+// normalization would not be a valid identifier.
+
+// xidStartExcluded holds characters in ID_Start that are not in XID_Start.
+var xidStartExcluded = &unicode.RangeTable{
+	R16: []unicode.Range16{
+		{Lo: 0x037a, Hi: 0x037a, Stride: 1},
+		{Lo: 0x0e33, Hi: 0x0e33, Stride: 1},
+		{Lo: 0x0eb3, Hi: 0x0eb3, Stride: 1},
+		{Lo: 0x309b, Hi: 0x309c, Stride: 1},
+		{Lo: 0xfc5e, Hi: 0xfc63, Stride: 1},
+		{Lo: 0xfdfa, Hi: 0xfdfb, Stride: 1},
+		{Lo: 0xfe70, Hi: 0xfe7e, Stride: 2},
+		{Lo: 0xff9e, Hi: 0xff9f, Stride: 1},
+	},
+}
+
+// xidContinueExcluded holds characters in ID_Continue that are not in XID_Continue.
+var xidContinueExcluded = &unicode.RangeTable{
+	R16: []unicode.Range16{
+		{Lo: 0x037a, Hi: 0x037a, Stride: 1},
+		{Lo: 0x309b, Hi: 0x309c, Stride: 1},
+		{Lo: 0xfc5e, Hi: 0xfc63, Stride: 1},
+		{Lo: 0xfdfa, Hi: 0xfdfb, Stride: 1},
+		{Lo: 0xfe70, Hi: 0xfe7e, Stride: 2},
+	},
+}
+
+var (
+	idStartTables    = []*unicode.RangeTable{unicode.L, unicode.Nl, unicode.Other_ID_Start}
+	idContinueTables = []*unicode.RangeTable{unicode.L, unicode.Nl, unicode.Other_ID_Start, unicode.Mn, unicode.Mc, unicode.Nd, unicode.Pc, unicode.Other_ID_Continue}
+)
+
+// isXIDStart reports whether ch may start an identifier: ch is an underscore or has the XID_Start property.
+func isXIDStart(ch rune) bool {
+	if ch < utf8.RuneSelf {
+		return isIdentifierChar(ch)
+	}
+	return unicode.IsOneOf(idStartTables, ch) && !isPatternChar(ch) && !unicode.Is(xidStartExcluded, ch)
+}
+
+// isXIDContinue reports whether ch may continue an identifier: ch has the XID_Continue property.
+func isXIDContinue(ch rune) bool {
+	if ch < utf8.RuneSelf {
+		return isIdentifierChar(ch) || isDigit(ch)
+	}
+	return unicode.IsOneOf(idContinueTables, ch) && !isPatternChar(ch) && !unicode.Is(xidContinueExcluded, ch)
+}
+
+// isPatternChar reports whether ch is reserved for pattern syntax or whitespace and thus never part of an identifier.
+func isPatternChar(ch rune) bool {
+	return unicode.Is(unicode.Pattern_Syntax, ch) || unicode.Is(unicode.Pattern_White_Space, ch)
+}
+
+// isIdentStart reports whether ch starts an identifier under the lexer's identifier rules.
+func (l *Lexer) isIdentStart(ch rune) bool {
+	if l.spec.identifiers == IdentUnicode {
+		return isXIDStart(ch)
+	}
+	return isIdentifierChar(ch)
+}
+
+// isIdentContinue reports whether ch continues an identifier under the lexer's identifier rules.
+func (l *Lexer) isIdentContinue(ch rune) bool {
+	if l.spec.identifiers == IdentUnicode {
+		return isXIDContinue(ch)
+	}
+	return isIdentifierChar(ch) || isDigit(ch)
+}
@@ -0,0 +1,22 @@
+// Code generated by "phashgen -type=Token -range=keywordBeg,keywordEnd -output=kwhash.go"; DO NOT EDIT.
+
+package pato
+
+// kwhashMinLen is the minimum length of input to kwhash.
+const kwhashMinLen = 2
+
+// kwhash is a perfect hash function for keywords.
+// Input must be at least kwhashMinLen bytes long.
+func kwhash(s string) uint {
+	h := uint(len(s))
+	h += uint(s[0]) << 1
+	h ^= uint(s[1]) << 1
+	return h & 3
+}
+
+// keywordMap holds keywords at the index given by kwhash.
+var keywordMap = [4]Token{
+	0: TokIf,   // if
+	1: TokFor,  // for
+	2: TokElse, // else
+}
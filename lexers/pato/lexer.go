@@ -2,29 +2,59 @@ package pato
 
 import (
 	"bufio"
+	"bytes"
 	"errors"
+	"fmt"
 	"io"
+	"iter"
 	"unicode/utf8"
 )
 
 // Peek length can be incremented in size and code should still work.
-const peeklen = 1
+const peeklen = 2
 const defaultBuflen = 512
 
 type Lexer struct {
 	input  bufio.Reader
 	ch     rune          // current character.
+	chsz   int           // size of current character.
 	peek   [peeklen]rune // peek characters.
 	peeksz [peeklen]int  // size of individual peek characters.
 	idbuf  []byte        // stores current identifier buildup.
+	valbuf []byte        // stores decoded string literal values.
 	err    error
 	source string
+	lines  *LineTable // line table being recorded.
+	base   int        // base added to byte offsets to form positions.
+	size   int        // size of the input in bytes or -1 if unbounded.
+	nread  int        // bytes read from input.
 	// positional indices.
 	line int
 	col  int
 	pos  int
+	// start position of last token.
+	tokPos, tokLine, tokCol int
+	lastErr                 error // error of last illegal token.
+
+	spec *compiledSpec
 
 	ReuseLiteralBuffer bool
+	// Spec is the language lexed. It is consumed on Reset. If nil [DefaultSpec] is used.
+	Spec *Spec
+	// KeepComments makes NextToken return comments as TokComment tokens.
+	// By default comments are skipped like whitespace.
+	KeepComments bool
+	// Lines, if set, is reset on Reset and records the source and its line starts
+	// as the lexer reads it, allowing fast position lookups. See [LineTable].
+	Lines *LineTable
+	// NormalizeIdentifier, if set, transforms non-ASCII identifiers before keyword lookup.
+	// It is typically NFC normalization, i.e: norm.NFC.Bytes from golang.org/x/text/unicode/norm.
+	NormalizeIdentifier func(ident []byte) []byte
+	// ErrorHandler, if set, is called with every lexing error. Lexing then resumes after the
+	// offending input and the error is not recorded in Err so that all errors can be collected in one pass.
+	// If not set only the first error is recorded and TokIllegal is returned on reaching the end of input.
+	ErrorHandler ErrorHandler
+	nerr         int
 }
 
 // LineCol returns the current line and column position in the source.
@@ -33,9 +63,30 @@ func (l *Lexer) LineCol() LineCol {
 }
 
 // Pos returns the current byte offset in the source.
-func (l *Lexer) Pos() Pos { return Pos(l.pos) }
+func (l *Lexer) Pos() Pos { return Pos(l.base + l.pos) }
+
+// Span is the extent of a token in the source.
+// End and EndLineCol are exclusive: they locate the character just past the token.
+type Span struct {
+	Start, End               Pos
+	StartLineCol, EndLineCol LineCol
+}
+
+// TokenSpan returns the span of the last token returned by NextToken.
+func (l *Lexer) TokenSpan() Span {
+	return Span{
+		Start:        Pos(l.base + l.tokPos),
+		End:          l.Pos(), // Whitespace after the token is skipped on the next call to NextToken.
+		StartLineCol: LineCol{Source: l.source, Line: l.tokLine, Col: l.tokCol},
+		EndLineCol:   l.LineCol(),
+	}
+}
+
+// ErrorCount returns the number of lexing errors encountered since the last Reset.
+func (l *Lexer) ErrorCount() int { return l.nerr }
 
 // Err returns the lexer error, or nil if the error is EOF.
+// Lexing errors are of type [*Error].
 func (l *Lexer) Err() error {
 	if l.err == io.EOF {
 		return nil
@@ -49,94 +100,302 @@ func (l *Lexer) IsDone() bool {
 }
 
 // Reset initializes the lexer with a new source name and reader.
-// It preserves ReuseLiteralBuffer and internal buffers across resets.
+// It preserves the exported configuration fields and internal buffers across resets.
+// The Spec is compiled into lookup tables if Spec points to a different Spec than on the last Reset.
 func (l *Lexer) Reset(source string, r io.Reader) error {
+	return l.reset(source, r, l.Lines, 0, -1)
+}
+
+// reset initializes the lexer recording lines into lines, if not nil,
+// with positions starting at base. Reading more than size bytes is an error if size is not negative.
+func (l *Lexer) reset(source string, r io.Reader, lines *LineTable, base, size int) error {
 	if r == nil {
 		return errors.New("nil reader")
 	} else if source == "" {
 		return errors.New("no source name")
 	}
+	spec := l.spec
+	if l.Spec == nil {
+		spec = defaultCompiled
+	} else if spec == nil || spec.src != l.Spec {
+		var err error
+		spec, err = compileSpec(l.Spec)
+		if err != nil {
+			return err
+		}
+	}
 	*l = Lexer{
-		ReuseLiteralBuffer: l.ReuseLiteralBuffer,
-		input:              l.input,
-		line:               1,
-		idbuf:              l.idbuf,
-		source:             source,
+		spec:                spec,
+		ReuseLiteralBuffer:  l.ReuseLiteralBuffer,
+		Spec:                l.Spec,
+		KeepComments:        l.KeepComments,
+		Lines:               l.Lines,
+		NormalizeIdentifier: l.NormalizeIdentifier,
+		ErrorHandler:        l.ErrorHandler,
+		input:               l.input,
+		line:                1,
+		idbuf:               l.idbuf,
+		valbuf:              l.valbuf,
+		source:              source,
+		lines:               lines,
+		base:                base,
+		size:                size,
 	}
 
 	l.input.Reset(r)
+	if l.lines != nil {
+		l.lines.reset(source)
+	}
 	// Fill up peek and current character.
 	const buflen = len(l.peek)
-	l.col = -buflen + 1 // col is 1 based.
+	l.col = -buflen // col is 1 based.
 	for range len(l.peek) {
 		l.advance() // fill peek buffer.
 	}
 	l.advance() // fill ch character.
+	if l.err == io.EOF {
+		return nil // Short input, NextToken returns TokEOF once consumed.
+	}
 	return l.err
 }
 
 // NextToken returns the next token, its starting byte position, and its literal value.
+// The full span of the token is available through TokenSpan until the next call.
 // Returns TokEOF at end of input, TokIllegal on errors.
+// Operators are matched by maximal munch so "<<=" yields TokShlAssign and not TokLess twice.
+// Numeric literals yield TokIntLit or TokFloatLit. Quoted literals yield TokStringLit,
+// TokRawStringLit or TokCharLit with the quotes included in literal.
 func (l *Lexer) NextToken() (tok Token, start Pos, literal []byte) {
 	if l.source == "" {
 		l.err = errors.New("lexer uninitialized")
 		return TokIllegal, 0, nil
 	}
-	l.skipWhitespace() // We skip early, not after tokenizing. This leads to more intuitive lexer behaviour.
-	start = l.Pos()
-	tok = LookupSingleChar(l.ch)
-	if tok == TokIllegal {
+	for {
+		l.skipWhitespace() // We skip early, not after tokenizing. This leads to more intuitive lexer behaviour.
+		start = l.Pos()
+		l.tokPos, l.tokLine, l.tokCol = l.pos, l.line, l.col
+		tok, literal = l.readComment()
+		if tok == TokUndefined {
+			break // No comment, proceed to tokenize.
+		} else if tok == TokIllegal || l.KeepComments {
+			return tok, start, literal
+		}
+	}
+	if l.IsDone() {
+		tok = TokIllegal
 		if l.err == io.EOF {
 			tok = TokEOF
 		}
 		return tok, start, nil
 	}
-	if tok != TokIDENT {
-		// Single character case.
-		literal = utf8.AppendRune(l.idbuf[l.bufstart():], l.ch)
-		l.advance()
-	} else if isDigit(l.ch) {
-		literal = l.readInteger()
-		tok = TokIntLit
-	} else {
+	spec := l.spec
+	if spec.isOperatorStart(l.ch) && !l.isNumberStart() {
+		// Operator and single character case.
+		if tok, literal = l.readOperator(); tok != TokUndefined {
+			return tok, start, literal
+		}
+	}
+	switch {
+	case l.isNumberStart():
+		tok, literal = l.readNumber()
+	case l.ch == '"' && spec.has(LitString):
+		tok, literal = l.readString()
+	case l.ch == '`' && spec.has(LitRawString):
+		tok, literal = l.readRawString()
+	case l.ch == '\'' && spec.has(LitChar):
+		tok, literal = l.readChar()
+	case l.isIdentStart(l.ch):
 		// We have an identifier in our hands.
 		literal = l.readIdentifier()
-		tok = Lookup(string(literal)) // Should be optimized by compiler to not allocate.
+		tok = spec.lookupKeyword(literal)
+	default:
+		literal = utf8.AppendRune(l.idbuf[l.bufstart():], l.ch)
+		l.errorf(CodeIllegalChar, start, l.LineCol(), literal, "illegal character %q", l.ch)
+		l.advance()
+		tok = TokIllegal
 	}
 	return tok, start, literal
 }
 
-func (l *Lexer) readIdentifier() []byte {
+// TokenInfo describes a token yielded by [Lexer.Tokens].
+type TokenInfo struct {
+	Tok   Token
+	Start Pos
+	// Literal of the token. It is overwritten by the next token if ReuseLiteralBuffer is set.
+	Literal []byte
+	// LineCol is the line and column at which the token starts.
+	LineCol LineCol
+	// End and EndLineCol locate the character just past the token.
+	End        Pos
+	EndLineCol LineCol
+}
+
+// Span returns the span of the token.
+func (ti TokenInfo) Span() Span {
+	return Span{Start: ti.Start, End: ti.End, StartLineCol: ti.LineCol, EndLineCol: ti.EndLineCol}
+}
+
+// Tokens returns an iterator over the remaining tokens of the lexer's input.
+// Iteration stops after the last token before EOF. Illegal tokens are yielded with
+// the error describing them. Iteration stops after an illegal token unless an
+// ErrorHandler is set, in which case lexing resumes.
+//
+//	for tok, err := range l.Tokens() {
+//		if err != nil {
+//			return err
+//		}
+//		fmt.Println(tok.LineCol, tok.Tok, string(tok.Literal))
+//	}
+func (l *Lexer) Tokens() iter.Seq2[TokenInfo, error] {
+	return func(yield func(TokenInfo, error) bool) {
+		for {
+			l.lastErr = nil
+			tok, start, literal := l.NextToken()
+			if tok == TokEOF {
+				return
+			}
+			span := l.TokenSpan()
+			info := TokenInfo{
+				Tok:        tok,
+				Start:      start,
+				Literal:    literal,
+				LineCol:    span.StartLineCol,
+				End:        span.End,
+				EndLineCol: span.EndLineCol,
+			}
+			var err error
+			if tok == TokIllegal {
+				err = l.lastErr
+				if err == nil {
+					err = l.Err() // Reader error or lexer uninitialized.
+				}
+			}
+			if !yield(info, err) || err != nil && (l.ErrorHandler == nil || l.IsDone()) {
+				return
+			}
+		}
+	}
+}
+
+// NextTokenValue is like NextToken but also returns the decoded value of string,
+// raw string and char literals, see [AppendUnquote]. value is nil for other tokens.
+// value is stored in a buffer owned by the Lexer which is overwritten on the next call.
+func (l *Lexer) NextTokenValue() (tok Token, start Pos, literal, value []byte) {
+	tok, start, literal = l.NextToken()
+	switch tok {
+	case TokStringLit, TokRawStringLit, TokCharLit:
+		if l.valbuf == nil {
+			l.valbuf = make([]byte, 0, 64)
+		}
+		// Literal was validated during lexing so decoding does not fail.
+		l.valbuf, _ = AppendUnquote(l.valbuf[:0], tok, literal)
+		value = l.valbuf
+	}
+	return tok, start, literal, value
+}
+
+// isNumberStart reports whether a number literal starts at the current character.
+func (l *Lexer) isNumberStart() bool {
+	if !isDigitOrDecimal(l.ch) || !l.spec.has(LitInt) {
+		return false
+	}
+	return l.ch != '.' || l.spec.has(LitFloat) && isDigit(l.peek[0])
+}
+
+// readOperator reads the longest operator starting at the current character
+// using the peek buffer. Returns TokUndefined and consumes nothing if there is no match.
+func (l *Lexer) readOperator() (Token, []byte) {
+	var buf [utf8.UTFMax * (peeklen + 1)]byte
+	op := buf[:0]
+	tok, n := TokUndefined, 0
+	for i := -1; i < len(l.peek); i++ {
+		c := l.ch
+		if i >= 0 {
+			c = l.peek[i]
+		}
+		op = utf8.AppendRune(op, c)
+		if t := l.spec.operators[string(op)]; t != 0 {
+			tok, n = t, i+2
+		}
+	}
+	if n == 0 {
+		return TokUndefined, nil
+	}
 	start := l.bufstart()
-	for isIdentifierChar(l.ch) || isDigit(l.ch) {
-		l.idbuf = utf8.AppendRune(l.idbuf, l.ch)
-		l.advance()
+	for range n {
+		l.consume()
 	}
-	return l.idbuf[start:]
+	return tok, l.idbuf[start:]
 }
 
-func (l *Lexer) readInteger() []byte {
+func (l *Lexer) readIdentifier() []byte {
 	start := l.bufstart()
-	for isDigit(l.ch) {
+	ascii := true
+	for l.isIdentContinue(l.ch) {
+		ascii = ascii && l.ch < utf8.RuneSelf
 		l.idbuf = utf8.AppendRune(l.idbuf, l.ch)
 		l.advance()
 	}
+	if !ascii && l.NormalizeIdentifier != nil {
+		l.idbuf = append(l.idbuf[:start], l.NormalizeIdentifier(l.idbuf[start:])...)
+	}
 	return l.idbuf[start:]
 }
 
+// consume appends the current character to the literal buffer and advances.
+func (l *Lexer) consume() {
+	l.idbuf = utf8.AppendRune(l.idbuf, l.ch)
+	l.advance()
+}
+
+// errorf reports a lexing error for the offending input starting at pos and lc.
+// The error is passed to ErrorHandler if set, otherwise the first non-EOF error is kept.
+func (l *Lexer) errorf(code ErrorCode, pos Pos, lc LineCol, offending []byte, format string, args ...any) {
+	l.nerr++
+	err := &Error{
+		Pos:     pos,
+		LineCol: lc,
+		Bytes:   bytes.Clone(offending),
+		Code:    code,
+		Msg:     fmt.Sprintf(format, args...),
+	}
+	l.lastErr = err
+	if l.ErrorHandler != nil {
+		l.ErrorHandler(err)
+	} else if l.err == nil || l.err == io.EOF {
+		l.err = err
+	}
+}
+
 func (l *Lexer) advance() {
 	// Advance character buffer first, so even on EOF we don't lose the last char
 	currentIsNewline := l.ch == '\n'
+	l.pos += l.chsz
 	l.ch = l.peek[0]
-	l.pos += l.peeksz[0]
+	l.chsz = l.peeksz[0]
 	for i := range len(l.peek) - 1 {
 		l.peek[i] = l.peek[i+1]
 		l.peeksz[i] = l.peeksz[i+1]
 	}
 	ch, sz, err := l.input.ReadRune()
+	if l.size >= 0 && l.nread+sz > l.size && err == nil {
+		// Input beyond the declared size would have positions of the next file.
+		ch, sz = 0, 0
+		err = fmt.Errorf("%s: input exceeds file size of %d bytes", l.source, l.size)
+	}
+	l.nread += sz
 	if err != nil && l.err == nil {
 		l.err = err // Set first error encountered.
 	}
+	if l.lines != nil && sz > 0 {
+		var raw byte
+		if ch == utf8.RuneError && sz == 1 {
+			// Recover invalid byte so recorded source matches input.
+			l.input.UnreadRune()
+			raw, _ = l.input.ReadByte()
+		}
+		l.lines.appendRune(ch, sz, raw)
+	}
 	l.col++
 	l.peek[len(l.peek)-1] = ch
 	l.peeksz[len(l.peek)-1] = sz
@@ -146,6 +405,26 @@ func (l *Lexer) advance() {
 	}
 }
 
+// hasPrefix reports whether the input starting at the current character begins with s.
+// Only the current and peek characters are inspected so s must be at most peeklen+1 characters long.
+func (l *Lexer) hasPrefix(s string) bool {
+	i := 0
+	for _, r := range s {
+		c := l.ch
+		if i > 0 {
+			if i > len(l.peek) {
+				return false
+			}
+			c = l.peek[i-1]
+		}
+		if c != r {
+			return false
+		}
+		i++
+	}
+	return i > 0
+}
+
 func (l *Lexer) bufstart() int {
 	if l.ReuseLiteralBuffer {
 		l.idbuf = l.idbuf[:0]
@@ -0,0 +1,631 @@
+package pato
+
+import (
+	"bytes"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/soypat/lexer/diag"
+)
+
+func TestNumericLiterals(t *testing.T) {
+	tests := []struct {
+		input string
+		tok   Token
+	}{
+		{"0", TokIntLit},
+		{"42", TokIntLit},
+		{"1_000_000", TokIntLit},
+		{"0x1F", TokIntLit},
+		{"0XdeadBEEF", TokIntLit},
+		{"0x_FF", TokIntLit},
+		{"0b_1", TokIntLit},
+		{"0x1_e", TokIntLit},
+		{"1e1_0", TokFloatLit},
+		{"0o17", TokIntLit},
+		{"017", TokIntLit},
+		{"0b1010", TokIntLit},
+		{"3.14", TokFloatLit},
+		{"1.", TokFloatLit},
+		{".5", TokFloatLit},
+		{"1e-9", TokFloatLit},
+		{"6.022E+23", TokFloatLit},
+		{"09.5", TokFloatLit},
+		{"0x1p-2", TokFloatLit},
+		{"0x1.8p3", TokFloatLit},
+		// Malformed literals.
+		{"0x", TokIllegal},
+		{"0b102", TokIllegal},
+		{"08", TokIllegal},
+		{"1e", TokIllegal},
+		{"1e+", TokIllegal},
+		{"0x1.8", TokIllegal},
+		{"0b1.0", TokIllegal},
+		{"0o1e3", TokIllegal},
+		{"1__0", TokIllegal},
+		{"10_", TokIllegal},
+		{"0_", TokIllegal},
+		{"1_.5", TokIllegal},
+		{"1e_1", TokIllegal},
+		{"0x1p_1", TokIllegal},
+	}
+	var l Lexer
+	for _, test := range tests {
+		err := l.Reset("test", strings.NewReader(test.input))
+		if err != nil {
+			t.Fatal(err)
+		}
+		tok, start, lit := l.NextToken()
+		if tok != test.tok {
+			t.Errorf("%q: got token %s, want %s (err=%v)", test.input, tok, test.tok, l.Err())
+			continue
+		}
+		if start != 0 || string(lit) != test.input {
+			t.Errorf("%q: got literal %q at %d", test.input, lit, start)
+		}
+		if tok == TokIllegal && l.Err() == nil {
+			t.Errorf("%q: expected error for malformed literal", test.input)
+		} else if tok != TokIllegal && l.Err() != nil {
+			t.Errorf("%q: unexpected error %v", test.input, l.Err())
+		}
+	}
+}
+
+func TestQuotedLiterals(t *testing.T) {
+	tests := []struct {
+		input string
+		tok   Token
+		value string
+	}{
+		{`""`, TokStringLit, ""},
+		{`"hello"`, TokStringLit, "hello"},
+		{`"a\tb\n"`, TokStringLit, "a\tb\n"},
+		{`"\x41\101é\U0001F600"`, TokStringLit, "AAé\U0001F600"},
+		{`"\xff"`, TokStringLit, "\xff"},
+		{`"say \"hi\""`, TokStringLit, `say "hi"`},
+		{"`raw\\n\r\nline`", TokRawStringLit, "raw\\n\nline"},
+		{`'a'`, TokCharLit, "a"},
+		{`'\''`, TokCharLit, "'"},
+		{`'\xff'`, TokCharLit, "ÿ"},
+		{`'世'`, TokCharLit, "世"},
+		// Malformed literals.
+		{`"unterminated`, TokIllegal, ""},
+		{"\"new\nline\"", TokIllegal, ""},
+		{`"\q"`, TokIllegal, ""},
+		{`"\x4"`, TokIllegal, ""},
+		{`"\uD800"`, TokIllegal, ""},
+		{`"\400"`, TokIllegal, ""},
+		{"`open", TokIllegal, ""},
+		{`''`, TokIllegal, ""},
+		{`'ab'`, TokIllegal, ""},
+	}
+	var l Lexer
+	for _, test := range tests {
+		err := l.Reset("test", strings.NewReader(test.input))
+		if err != nil {
+			t.Fatal(err)
+		}
+		tok, _, _, value := l.NextTokenValue()
+		if tok != test.tok {
+			t.Errorf("%q: got token %s, want %s (err=%v)", test.input, tok, test.tok, l.Err())
+			continue
+		}
+		if tok == TokIllegal {
+			if l.Err() == nil {
+				t.Errorf("%q: expected error for malformed literal", test.input)
+			}
+			continue
+		}
+		if string(value) != test.value {
+			t.Errorf("%q: got value %q, want %q", test.input, value, test.value)
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	const input = "a // line\nb /* block\n */ c /* outer /* inner */ */ d -- sql"
+	tests := []struct {
+		syntax CommentSyntax
+		keep   bool
+		want   []string
+	}{
+		{
+			syntax: CommentsC,
+			want:   []string{"a", "\n", "b", "c", "*", "/", "d", "--", "sql"},
+		},
+		{
+			syntax: CommentsC,
+			keep:   true,
+			want:   []string{"a", "// line", "\n", "b", "/* block\n */", "c", "/* outer /* inner */", "*", "/", "d", "--", "sql"},
+		},
+		{
+			syntax: CommentSyntax{Line: []string{"//", "--"}, Block: CommentsC.Block, Nested: true},
+			keep:   true,
+			want:   []string{"a", "// line", "\n", "b", "/* block\n */", "c", "/* outer /* inner */ */", "d", "-- sql"},
+		},
+	}
+	var l Lexer
+	for i, test := range tests {
+		spec := DefaultSpec()
+		spec.Comments = test.syntax
+		l.Spec = &spec
+		l.KeepComments = test.keep
+		err := l.Reset("test", strings.NewReader(input))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for {
+			tok, _, lit := l.NextToken()
+			if tok == TokEOF || tok == TokIllegal {
+				break
+			}
+			got = append(got, string(lit))
+		}
+		if l.Err() != nil {
+			t.Fatalf("test %d: %v", i, l.Err())
+		}
+		if !slices.Equal(got, test.want) {
+			t.Errorf("test %d:\ngot  %q\nwant %q", i, got, test.want)
+		}
+	}
+	l.KeepComments = false
+	l.Reset("test", strings.NewReader("a /* never closed"))
+	l.NextToken()
+	if tok, _, _ := l.NextToken(); tok != TokIllegal || l.Err() == nil {
+		t.Errorf("expected error for unterminated comment, got %s", tok)
+	}
+}
+
+func TestOperators(t *testing.T) {
+	const input = "a<<=b<-c... x:=y**2 != z&^=w -> .5 ..+=<"
+	want := []Token{
+		TokIDENT, TokShlAssign, TokIDENT, TokLArrow, TokIDENT, TokEllipsis,
+		TokIDENT, TokDefine, TokIDENT, TokPow, TokIntLit, TokNotEq, TokIDENT, TokAndNotAssign, TokIDENT,
+		TokArrow, TokFloatLit, TokPeriod, TokPeriod, TokPlusAssign, TokLess, TokEOF,
+	}
+	var l Lexer
+	err := l.Reset("test", strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, wantTok := range want {
+		tok, _, lit := l.NextToken()
+		if tok != wantTok {
+			t.Fatalf("token %d: got %s %q, want %s", i, tok, lit, wantTok)
+		}
+		if tok > operatorBeg && tok < operatorEnd && string(lit) != tok.String() {
+			t.Errorf("token %d: got literal %q for operator %s", i, lit, tok)
+		}
+	}
+	for tok := operatorBeg + 1; tok < operatorEnd; tok++ {
+		if got := LookupOperator(tok.String()); got != tok {
+			t.Errorf("LookupOperator(%q)=%s", tok.String(), got)
+		}
+	}
+}
+
+func TestUnicodeIdentifiers(t *testing.T) {
+	const input = "résumé 変数+Δt _x1 e\u0301te\u0301 ℘ ·"
+	var l Lexer
+	spec := DefaultSpec()
+	spec.Identifiers = IdentUnicode
+	l.Spec = &spec
+	l.NormalizeIdentifier = func(ident []byte) []byte {
+		return bytes.ReplaceAll(ident, []byte("e\u0301"), []byte("\u00e9")) // Toy NFC composition.
+	}
+	err := l.Reset("test", strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		tok Token
+		lit string
+	}{
+		{TokIDENT, "résumé"},
+		{TokIDENT, "変数"},
+		{TokPlus, "+"},
+		{TokIDENT, "Δt"},
+		{TokIDENT, "_x1"},
+		{TokIDENT, "\u00e9t\u00e9"},
+		{TokIDENT, "℘"}, // Other_ID_Start.
+		{TokIllegal, "·"},
+		{TokIllegal, ""},
+	}
+	for i, w := range want {
+		tok, _, lit := l.NextToken()
+		if tok != w.tok || string(lit) != w.lit {
+			t.Errorf("token %d: got %s %q, want %s %q", i, tok, lit, w.tok, w.lit)
+		}
+	}
+
+	l.Spec = nil
+	l.Reset("test", strings.NewReader("Δt"))
+	if tok, _, _ := l.NextToken(); tok != TokIllegal {
+		t.Errorf("expected illegal token for non-ASCII identifier in ASCII mode, got %s", tok)
+	}
+}
+
+func TestErrorHandler(t *testing.T) {
+	const input = "a $ 0b12 \"\\q\" '' b\n\"open\nc /* never closed"
+	var errs []*Error
+	var l Lexer
+	spec := DefaultSpec()
+	spec.Comments = CommentsC
+	l.Spec = &spec
+	l.ErrorHandler = func(err *Error) { errs = append(errs, err) }
+	err := l.Reset("test", strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var idents []string
+	for {
+		tok, _, lit := l.NextToken()
+		if tok == TokEOF {
+			break
+		} else if tok == TokIDENT {
+			idents = append(idents, string(lit))
+		}
+	}
+	if l.Err() != nil {
+		t.Error("unexpected error with error handler set:", l.Err())
+	}
+	if !slices.Equal(idents, []string{"a", "b", "c"}) {
+		t.Errorf("lexing did not resume after errors, got identifiers %q", idents)
+	}
+	want := []struct {
+		code  ErrorCode
+		bytes string
+	}{
+		{CodeIllegalChar, "$"},
+		{CodeBadNumber, "0b12"},
+		{CodeBadEscape, `\q`},
+		{CodeBadCharLiteral, "''"},
+		{CodeUnterminatedLiteral, `"open`},
+		{CodeUnterminatedComment, "/*"},
+	}
+	if len(errs) != len(want) || l.ErrorCount() != len(want) {
+		t.Fatalf("got %d errors (count %d), want %d: %v", len(errs), l.ErrorCount(), len(want), errs)
+	}
+	for i, w := range want {
+		if errs[i].Code != w.code || string(errs[i].Bytes) != w.bytes {
+			t.Errorf("error %d: got %s %q, want %s %q", i, errs[i].Code, errs[i].Bytes, w.code, w.bytes)
+		}
+	}
+	if got := errs[4].LineCol.Line; got != 2 {
+		t.Errorf("want unterminated string on line 2, got %d", got)
+	}
+
+	// Without handler the first error is kept.
+	l.ErrorHandler = nil
+	l.Reset("test", strings.NewReader(input))
+	for tok := TokUndefined; tok != TokEOF && !l.IsDone(); tok, _, _ = l.NextToken() {
+	}
+	var lexErr *Error
+	if !errors.As(l.Err(), &lexErr) || lexErr.Code != CodeIllegalChar {
+		t.Errorf("want first error to be illegal character, got %v", l.Err())
+	}
+}
+
+func TestSpec(t *testing.T) {
+	const (
+		tokLocal = TokUser + iota
+		tokEnd
+		tokNotEq
+		tokConcat
+	)
+	spec := Spec{
+		Keywords: []Symbol{{"local", tokLocal}, {"end", tokEnd}, {"if", TokIf}},
+		Operators: []Symbol{
+			{"=", TokAssign}, {"~=", tokNotEq}, {"..", tokConcat}, {"(", TokLParen}, {")", TokRParen},
+		},
+		Comments: CommentSyntax{Line: []string{"--"}},
+		Literals: LitInt | LitString,
+	}
+	const input = "local x = 10 -- note\nif x ~= \"a\"..y end 'q' 1.5"
+	want := []Token{
+		tokLocal, TokIDENT, TokAssign, TokIntLit, TokIf, TokIDENT, tokNotEq, TokStringLit, tokConcat, TokIDENT, tokEnd,
+		TokIllegal, TokIDENT, TokIllegal, TokIntLit, TokIllegal, TokIntLit, TokEOF,
+	}
+	var l Lexer
+	l.Spec = &spec
+	l.ErrorHandler = func(*Error) {}
+	err := l.Reset("test", strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, wantTok := range want {
+		tok, _, lit := l.NextToken()
+		if tok != wantTok {
+			t.Fatalf("token %d: got %s %q, want %s", i, tok, lit, wantTok)
+		}
+	}
+	if l.ErrorCount() != 3 {
+		t.Errorf("want 3 errors for illegal characters, got %d", l.ErrorCount())
+	}
+
+	spec.Operators = append(spec.Operators, Symbol{"....", TokEllipsis})
+	l.Spec = &Spec{Operators: spec.Operators}
+	if err := l.Reset("test", strings.NewReader(input)); err == nil {
+		t.Error("expected error for operator exceeding lookahead")
+	}
+
+	// Modifying the Spec does not affect lexing in progress.
+	spec = DefaultSpec()
+	l.Spec = &spec
+	if err := l.Reset("test", strings.NewReader("1 # x 'c'")); err != nil {
+		t.Fatal(err)
+	}
+	spec.Comments = CommentsShell
+	spec.Literals = LitInt
+	spec.Identifiers = IdentUnicode
+	if tok, _, _ := l.NextToken(); tok != TokIntLit {
+		t.Fatalf("got %s, want %s", tok, TokIntLit)
+	}
+	if tok, _, _ := l.NextToken(); tok != TokIllegal {
+		t.Errorf("got %s, want %s for '#' not a comment of the Spec on Reset", tok, TokIllegal)
+	}
+	if def := DefaultSpec(); def.Comments.Line != nil || def.Literals != LitAll {
+		t.Error("DefaultSpec modified")
+	}
+}
+
+// TestKeywordLookup checks the generated keyword table is up to date.
+// Run go generate if it fails after adding keywords.
+func TestKeywordLookup(t *testing.T) {
+	for tok := keywordBeg + 1; tok < keywordEnd; tok++ {
+		if got := Lookup(tok.String()); got != tok {
+			t.Errorf("Lookup(%q)=%s, want %s", tok.String(), got, tok)
+		} else if !IsKeyword(tok.String()) {
+			t.Errorf("IsKeyword(%q)=false", tok.String())
+		}
+	}
+	for _, ident := range []string{"i", "fi", "iff", "elsa", "x"} {
+		if tok := Lookup(ident); tok != TokIDENT {
+			t.Errorf("Lookup(%q)=%s, want identifier", ident, tok)
+		}
+	}
+}
+
+func TestTokens(t *testing.T) {
+	const input = "if x {\n\ty = 0x1F\n} $ else"
+	var l Lexer
+	err := l.Reset("test", strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []Token
+	var lexErr error
+	for tok, err := range l.Tokens() {
+		got = append(got, tok.Tok)
+		if err != nil {
+			lexErr = err
+		} else if tok.Tok == TokIntLit && (tok.LineCol.Line != 2 || string(tok.Literal) != "0x1F") {
+			t.Errorf("got integer %q at %s", tok.Literal, tok.LineCol)
+		}
+	}
+	want := []Token{TokIf, TokIDENT, TokLBrace, TokNewline, TokIDENT, TokAssign, TokIntLit, TokNewline, TokRBrace, TokIllegal}
+	if !slices.Equal(got, want) {
+		t.Errorf("got tokens %v, want %v", got, want)
+	}
+	var e *Error
+	if !errors.As(lexErr, &e) || e.Code != CodeIllegalChar {
+		t.Errorf("want illegal character error, got %v", lexErr)
+	}
+
+	// Iteration resumes after errors with an ErrorHandler.
+	l.ErrorHandler = func(*Error) {}
+	l.Reset("test", strings.NewReader(input))
+	got = got[:0]
+	for tok := range l.Tokens() {
+		got = append(got, tok.Tok)
+	}
+	want = append(want, TokElse)
+	if !slices.Equal(got, want) {
+		t.Errorf("got tokens %v, want %v", got, want)
+	}
+}
+
+func TestShortInput(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []Token
+	}{
+		{"", []Token{TokEOF}},
+		{"x", []Token{TokIDENT, TokEOF}},
+		{"ab", []Token{TokIDENT, TokEOF}},
+		{"x;", []Token{TokIDENT, TokSemicolon, TokEOF}},
+		{"<<", []Token{TokShl, TokEOF}},
+	}
+	var l Lexer
+	for _, test := range tests {
+		if err := l.Reset("test", strings.NewReader(test.input)); err != nil {
+			t.Fatalf("%q: %v", test.input, err)
+		}
+		var got []Token
+		for len(got) < 10 {
+			tok, _, _ := l.NextToken()
+			got = append(got, tok)
+			if tok == TokEOF || tok == TokIllegal {
+				break
+			}
+		}
+		if !slices.Equal(got, test.want) {
+			t.Errorf("%q: got tokens %v, want %v", test.input, got, test.want)
+		}
+	}
+}
+
+func TestTokenSpan(t *testing.T) {
+	const input = "if x {\n\ty = \"é\" // c\n}"
+	want := []struct {
+		tok                        Token
+		start, end                 Pos
+		line, col, endLine, endCol int
+	}{
+		{TokIf, 0, 2, 1, 1, 1, 3},
+		{TokIDENT, 3, 4, 1, 4, 1, 5},
+		{TokLBrace, 5, 6, 1, 6, 1, 7},
+		{TokNewline, 6, 7, 1, 7, 2, 1},
+		{TokIDENT, 8, 9, 2, 2, 2, 3},
+		{TokAssign, 10, 11, 2, 4, 2, 5},
+		{TokStringLit, 12, 16, 2, 6, 2, 9}, // é is two bytes and one column.
+		{TokComment, 17, 21, 2, 10, 2, 14},
+		{TokNewline, 21, 22, 2, 14, 3, 1},
+		{TokRBrace, 22, 23, 3, 1, 3, 2},
+		{TokEOF, 23, 23, 3, 2, 3, 2},
+	}
+	var l Lexer
+	spec := DefaultSpec()
+	spec.Comments = CommentsC
+	l.Spec = &spec
+	l.KeepComments = true
+	err := l.Reset("test", strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, w := range want {
+		tok, start, _ := l.NextToken()
+		span := l.TokenSpan()
+		if tok != w.tok || start != w.start || span.Start != start || span.End != w.end {
+			t.Errorf("token %d: got %s [%d,%d), want %s [%d,%d)", i, tok, span.Start, span.End, w.tok, w.start, w.end)
+		}
+		got := [4]int{span.StartLineCol.Line, span.StartLineCol.Col, span.EndLineCol.Line, span.EndLineCol.Col}
+		if got != [4]int{w.line, w.col, w.endLine, w.endCol} {
+			t.Errorf("token %d %s: got line:col span %v, want %v", i, tok, got, [4]int{w.line, w.col, w.endLine, w.endCol})
+		}
+	}
+}
+
+func TestLineTable(t *testing.T) {
+	const input = "if x {\r\n\ty = \"é😀\" + z\n}\n\nw"
+	scanned := NewLineTable("test", []byte(input))
+	var l Lexer
+	l.Lines = new(LineTable)
+	err := l.Reset("test", strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for tok, err := range l.Tokens() {
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, lt := range []*LineTable{scanned, l.Lines} {
+			if got := lt.LineCol(tok.Start, ColumnRunes); got != tok.LineCol {
+				t.Errorf("%s %q: got %s, lexer reports %s", tok.Tok, tok.Literal, got, tok.LineCol)
+			}
+		}
+	}
+	if string(l.Lines.Bytes()) != input || l.Lines.LineCount() != 5 || scanned.LineCount() != 5 {
+		t.Fatalf("recorded source %q with %d lines", l.Lines.Bytes(), l.Lines.LineCount())
+	}
+	aux := make([]byte, 4)
+	zpos := Pos(strings.Index(input, "z"))
+	for _, test := range []struct {
+		unit ColumnUnit
+		col  int
+	}{{ColumnRunes, 13}, {ColumnBytes, 17}, {ColumnUTF16, 14}} {
+		lc := scanned.LineCol(zpos, test.unit)
+		if lc.Line != 2 || lc.Col != test.col {
+			t.Errorf("unit %d: got %s, want col %d", test.unit, lc, test.col)
+		}
+		if test.unit == ColumnBytes {
+			line, col, _, err := zpos.ToLineCol(strings.NewReader(input), aux)
+			if err != nil || line != lc.Line || col != lc.Col {
+				t.Errorf("ToLineCol mismatch: got %d:%d, want %s", line, col, lc)
+			}
+		}
+	}
+	for line, want := range []string{"if x {", "\ty = \"é😀\" + z", "}", "", "w"} {
+		if got := string(scanned.LineText(line + 1)); got != want {
+			t.Errorf("line %d: got text %q, want %q", line+1, got, want)
+		}
+	}
+	if scanned.LineCol(Pos(len(input)+1), ColumnRunes).Line != 0 || scanned.LineText(6) != nil {
+		t.Error("expected zero values for out of range lookups")
+	}
+}
+
+func TestFileSet(t *testing.T) {
+	sources := []struct{ name, src string }{
+		{"a.go", "if x {\n\ty\n}"},
+		{"b.go", "for\n  z"},
+	}
+	var fset FileSet
+	var l Lexer
+	type located struct {
+		pos Pos
+		lc  LineCol
+	}
+	var got []located
+	for _, s := range sources {
+		f := fset.AddFile(s.name, len(s.src))
+		err := l.ResetFile(f, strings.NewReader(s.src))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for tok, err := range l.Tokens() {
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, located{pos: tok.Start, lc: tok.LineCol})
+		}
+	}
+	if len(got) != 10 { // Newlines are tokens in DefaultSpec.
+		t.Fatalf("got %d tokens", len(got))
+	}
+	for _, g := range got {
+		if lc := fset.LineCol(g.pos); lc != g.lc {
+			t.Errorf("pos %d: got %s, want %s", g.pos, lc, g.lc)
+		}
+	}
+	if got[len(got)-1].lc.String() != "b.go:2:3" {
+		t.Errorf("got last token at %s", got[len(got)-1].lc)
+	}
+	if fset.File(NoPos) != nil || fset.File(Pos(len(sources[0].src)+len(sources[1].src)+3)) != nil {
+		t.Error("expected no file for out of range positions")
+	}
+
+	// Input beyond the declared size of a file is an error.
+	f := fset.AddFile("c.go", 5)
+	next := fset.AddFile("d.go", 1)
+	if err := l.ResetFile(f, strings.NewReader("abc defgh")); err != nil {
+		t.Fatal(err)
+	}
+	var toks []Token
+	for tok := TokUndefined; tok != TokEOF && tok != TokIllegal; {
+		var start Pos
+		tok, start, _ = l.NextToken()
+		toks = append(toks, tok)
+		if start >= next.Base() {
+			t.Errorf("%s at %d past file of size %d", tok, start, f.Size())
+		}
+	}
+	if want := []Token{TokIDENT, TokIDENT, TokIllegal}; !slices.Equal(toks, want) || l.Err() == nil {
+		t.Errorf("got tokens %v and error %v, want %v and an error", toks, l.Err(), want)
+	}
+	if got := string(f.Lines().Bytes()); got != "abc d" {
+		t.Errorf("got recorded source %q", got)
+	}
+}
+
+func TestDiagnostic(t *testing.T) {
+	var l Lexer
+	var lt LineTable
+	l.Lines = &lt
+	l.Reset("main.pato", strings.NewReader("x := 0b12\n"))
+	for tok := TokUndefined; tok != TokEOF && !l.IsDone(); tok, _, _ = l.NextToken() {
+	}
+	var lexErr *Error
+	if !errors.As(l.Err(), &lexErr) {
+		t.Fatal("expected lexing error, got", l.Err())
+	}
+	var p diag.Printer
+	got := string(p.Append(nil, lt.DiagSource(), lexErr.Diagnostic()))
+	want := "main.pato:1:6: " + lexErr.Msg + "\nx := 0b12\n     ^~~~\n"
+	if got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,155 @@
+package pato
+
+import (
+	"bytes"
+	"io"
+	"sort"
+	"unicode/utf8"
+
+	"github.com/soypat/lexer/diag"
+)
+
+// ColumnUnit selects the unit in which columns are counted.
+type ColumnUnit uint8
+
+const (
+	ColumnRunes ColumnUnit = iota // Columns count Unicode code points, as the Lexer does.
+	ColumnBytes                   // Columns count bytes.
+	ColumnUTF16                   // Columns count UTF-16 code units, as used by LSP and JavaScript.
+)
+
+// LineTable holds a source and the offsets at which its lines start
+// so that positions convert to line and column in O(log n), unlike [Pos.ToLineCol]
+// which rescans the source on every call.
+// A LineTable is built from a one-time scan with [NewLineTable] or [ReadLineTable]
+// or recorded during lexing by setting [Lexer.Lines].
+type LineTable struct {
+	source string
+	src    []byte
+	lines  []int // Offsets of line starts. lines[0] is always 0.
+	shared bool  // src is owned by the user and must not be appended to.
+}
+
+// NewLineTable returns the line table of src. The LineTable keeps a reference to src.
+func NewLineTable(source string, src []byte) *LineTable {
+	lt := &LineTable{}
+	lt.reset(source)
+	lt.src = src
+	lt.shared = true
+	for off := 0; ; {
+		idx := bytes.IndexByte(src[off:], '\n')
+		if idx < 0 {
+			break
+		}
+		off += idx + 1
+		lt.lines = append(lt.lines, off)
+	}
+	return lt
+}
+
+// ReadLineTable reads r until EOF and returns the line table of its contents.
+func ReadLineTable(source string, r io.Reader) (*LineTable, error) {
+	src, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return NewLineTable(source, src), nil
+}
+
+func (lt *LineTable) reset(source string) {
+	lt.source = source
+	if lt.shared {
+		lt.src, lt.shared = nil, false
+	}
+	lt.src = lt.src[:0]
+	lt.lines = append(lt.lines[:0], 0)
+}
+
+// appendRune records a character of size sz read by the lexer.
+// raw is the source byte of invalid UTF-8 which decodes to utf8.RuneError with size 1.
+func (lt *LineTable) appendRune(ch rune, sz int, raw byte) {
+	if ch == utf8.RuneError && sz == 1 {
+		lt.src = append(lt.src, raw)
+	} else {
+		lt.src = utf8.AppendRune(lt.src, ch)
+	}
+	if ch == '\n' {
+		lt.lines = append(lt.lines, len(lt.src))
+	}
+}
+
+// Source returns the source name of the table.
+func (lt *LineTable) Source() string { return lt.source }
+
+// Bytes returns the source text held by the table.
+func (lt *LineTable) Bytes() []byte { return lt.src }
+
+// DiagSource returns the source held by the table for rendering diagnostics.
+func (lt *LineTable) DiagSource() *diag.Source {
+	return diag.NewSource(lt.source, 0, lt.src)
+}
+
+// LineCount returns the number of lines in the table.
+func (lt *LineTable) LineCount() int { return len(lt.lines) }
+
+// Line returns the 1-based line containing pos, or 0 if pos is out of range.
+func (lt *LineTable) Line(pos Pos) int {
+	if pos < 0 || int(pos) > len(lt.src) {
+		return 0
+	}
+	// Find first line starting after pos, pos belongs to the line before it.
+	return sort.SearchInts(lt.lines, int(pos)+1)
+}
+
+// LineStart returns the offset at which line starts, or -1 if line is out of range.
+func (lt *LineTable) LineStart(line int) Pos {
+	if line < 1 || line > len(lt.lines) {
+		return -1
+	}
+	return Pos(lt.lines[line-1])
+}
+
+// LineText returns the text of line without its line terminator, suitable for source snippets.
+// It returns nil if line is out of range.
+func (lt *LineTable) LineText(line int) []byte {
+	if line < 1 || line > len(lt.lines) {
+		return nil
+	}
+	start := lt.lines[line-1]
+	end := len(lt.src)
+	if line < len(lt.lines) {
+		end = lt.lines[line] - 1 // Exclude newline.
+	}
+	text := lt.src[start:end]
+	if len(text) > 0 && text[len(text)-1] == '\r' {
+		text = text[:len(text)-1]
+	}
+	return text
+}
+
+// LineCol returns the 1-based line and column of pos with columns counted in unit.
+// It returns the zero LineCol if pos is out of range.
+func (lt *LineTable) LineCol(pos Pos, unit ColumnUnit) LineCol {
+	line := lt.Line(pos)
+	if line == 0 {
+		return LineCol{}
+	}
+	prefix := lt.src[lt.lines[line-1]:pos]
+	col := 1
+	switch unit {
+	case ColumnBytes:
+		col += len(prefix)
+	case ColumnRunes:
+		col += utf8.RuneCount(prefix)
+	case ColumnUTF16:
+		for len(prefix) > 0 {
+			r, sz := utf8.DecodeRune(prefix)
+			prefix = prefix[sz:]
+			col++
+			if r >= 0x10000 {
+				col++ // Surrogate pair.
+			}
+		}
+	}
+	return LineCol{Source: lt.source, Line: line, Col: col}
+}
@@ -0,0 +1,373 @@
+package pato
+
+import (
+	"errors"
+	"strconv"
+	"unicode"
+	"unicode/utf8"
+)
+
+// readNumber reads a numeric literal using Go's syntax: decimal, hexadecimal (0x),
+// octal (0o or a leading 0) and binary (0b) integers, decimal and hexadecimal
+// floating point numbers with exponents, and '_' digit separators.
+// Floating point forms are only recognized if the Spec has LitFloat set.
+// Returns TokIntLit or TokFloatLit. Malformed literals are consumed in full,
+// the lexer error is set and TokIllegal is returned alongside the offending bytes.
+func (l *Lexer) readNumber() (Token, []byte) {
+	start := l.bufstart()
+	pos, lc := l.Pos(), l.LineCol()
+	tok := TokIntLit
+	base := 10
+	prefix := rune(0)
+	digsep := 0 // bit 0: digit present, bit 1: '_' present.
+	invalid := rune(-1)
+	var reason string
+
+	// Integer part.
+	if l.ch != '.' {
+		if l.ch == '0' {
+			l.consume()
+			switch lower(l.ch) {
+			case 'x':
+				l.consume()
+				base, prefix = 16, 'x'
+			case 'o':
+				l.consume()
+				base, prefix = 8, 'o'
+			case 'b':
+				l.consume()
+				base, prefix = 2, 'b'
+			default:
+				base, prefix = 8, '0'
+				digsep = 1 // Leading 0 is a digit.
+			}
+		}
+		digsep |= l.readDigits(base, &invalid)
+	}
+	floats := l.spec.has(LitFloat)
+	// Fractional part.
+	if l.ch == '.' && floats {
+		tok = TokFloatLit
+		if prefix == 'o' || prefix == 'b' {
+			reason = "invalid radix point in " + litname(prefix)
+		}
+		l.consume()
+		digsep |= l.readDigits(base, &invalid)
+	}
+	if digsep&1 == 0 && reason == "" {
+		reason = litname(prefix) + " has no digits"
+	}
+	// Exponent.
+	if e := lower(l.ch); floats && (e == 'e' || e == 'p') {
+		if reason == "" {
+			switch {
+			case e == 'e' && prefix != 0 && prefix != '0':
+				reason = "'e' exponent requires decimal mantissa"
+			case e == 'p' && prefix != 'x':
+				reason = "'p' exponent requires hexadecimal mantissa"
+			}
+		}
+		l.consume()
+		tok = TokFloatLit
+		if l.ch == '+' || l.ch == '-' {
+			l.consume()
+		}
+		ds := l.readDigits(10, nil)
+		digsep |= ds
+		if ds&1 == 0 && reason == "" {
+			reason = "exponent has no digits"
+		}
+	} else if prefix == 'x' && tok == TokFloatLit && reason == "" {
+		reason = "hexadecimal mantissa requires a 'p' exponent"
+	}
+	literal := l.idbuf[start:]
+	if reason == "" && tok == TokIntLit && invalid >= 0 {
+		reason = "invalid digit " + strconv.QuoteRune(invalid) + " in " + litname(prefix)
+	}
+	if reason == "" && digsep&2 != 0 && invalidSep(literal) >= 0 {
+		reason = "'_' must separate successive digits"
+	}
+	if reason != "" {
+		l.errorf(CodeBadNumber, pos, lc, literal, "%s: %q", reason, literal)
+		return TokIllegal, literal
+	}
+	return tok, literal
+}
+
+// readDigits consumes digits of the given base and '_' separators.
+// For bases up to 10 all decimal digits are consumed and the first digit
+// not valid in base is stored in invalid, if invalid is not nil.
+// It returns a bitset: bit 0 is set if a digit was read, bit 1 if a separator was read.
+func (l *Lexer) readDigits(base int, invalid *rune) (digsep int) {
+	if base <= 10 {
+		max := rune('0' + base)
+		for isDigit(l.ch) || l.ch == '_' {
+			ds := 1
+			if l.ch == '_' {
+				ds = 2
+			} else if l.ch >= max && invalid != nil && *invalid < 0 {
+				*invalid = l.ch
+			}
+			digsep |= ds
+			l.consume()
+		}
+	} else {
+		for isHex(l.ch) || l.ch == '_' {
+			ds := 1
+			if l.ch == '_' {
+				ds = 2
+			}
+			digsep |= ds
+			l.consume()
+		}
+	}
+	return digsep
+}
+
+// invalidSep returns the index of the first '_' in x that is not both preceded
+// and followed by a digit, or -1. A base prefix such as 0x counts as a digit
+// so 0x_1F is valid, and hexadecimal digits are digits only after 0x.
+func invalidSep(x []byte) int {
+	prefixLen := 0
+	if len(x) >= 2 && x[0] == '0' {
+		switch lower(rune(x[1])) {
+		case 'x', 'o', 'b':
+			prefixLen = 2
+		}
+	}
+	hex := prefixLen == 2 && lower(rune(x[1])) == 'x'
+	digit := func(i int) bool {
+		if i < 0 || i >= len(x) {
+			return false
+		}
+		return isDigit(rune(x[i])) || hex && i >= prefixLen && isHex(rune(x[i]))
+	}
+	for i, c := range x {
+		if c != '_' {
+			continue
+		}
+		after := digit(i + 1)
+		before := digit(i-1) || i > 0 && i == prefixLen
+		if !before || !after {
+			return i
+		}
+	}
+	return -1
+}
+
+// litname names the numeric literal of the given base prefix in error messages.
+func litname(prefix rune) string {
+	switch prefix {
+	case 'x':
+		return "hexadecimal literal"
+	case 'o', '0':
+		return "octal literal"
+	case 'b':
+		return "binary literal"
+	}
+	return "decimal literal"
+}
+
+// readString reads an interpreted string literal delimited by double quotes.
+// Escape sequences are validated but not decoded; see [AppendUnquote].
+// The returned literal includes the quotes.
+func (l *Lexer) readString() (Token, []byte) {
+	start := l.bufstart()
+	pos, lc := l.Pos(), l.LineCol()
+	valid := true
+	l.consume() // Opening quote.
+	for l.ch != '"' {
+		if l.ch == '\n' || l.IsDone() {
+			l.errorf(CodeUnterminatedLiteral, pos, lc, l.idbuf[start:], "string literal not terminated")
+			return TokIllegal, l.idbuf[start:]
+		}
+		if l.ch == '\\' {
+			valid = l.readEscape('"') && valid
+		} else {
+			l.consume()
+		}
+	}
+	l.consume() // Closing quote.
+	if !valid {
+		return TokIllegal, l.idbuf[start:]
+	}
+	return TokStringLit, l.idbuf[start:]
+}
+
+// readRawString reads a raw string literal delimited by backticks.
+// Raw strings may span several lines and contain no escape sequences.
+func (l *Lexer) readRawString() (Token, []byte) {
+	start := l.bufstart()
+	pos, lc := l.Pos(), l.LineCol()
+	l.consume() // Opening backtick.
+	for l.ch != '`' {
+		if l.IsDone() {
+			l.errorf(CodeUnterminatedLiteral, pos, lc, l.idbuf[start:], "raw string literal not terminated")
+			return TokIllegal, l.idbuf[start:]
+		}
+		l.consume()
+	}
+	l.consume() // Closing backtick.
+	return TokRawStringLit, l.idbuf[start:]
+}
+
+// readChar reads a character literal delimited by single quotes
+// which must contain exactly one character or escape sequence.
+func (l *Lexer) readChar() (Token, []byte) {
+	start := l.bufstart()
+	pos, lc := l.Pos(), l.LineCol()
+	valid := true
+	n := 0
+	l.consume() // Opening quote.
+	for l.ch != '\'' {
+		if l.ch == '\n' || l.IsDone() {
+			l.errorf(CodeUnterminatedLiteral, pos, lc, l.idbuf[start:], "rune literal not terminated")
+			return TokIllegal, l.idbuf[start:]
+		}
+		n++
+		if l.ch == '\\' {
+			valid = l.readEscape('\'') && valid
+		} else {
+			l.consume()
+		}
+	}
+	l.consume() // Closing quote.
+	if valid && n != 1 {
+		if n == 0 {
+			l.errorf(CodeBadCharLiteral, pos, lc, l.idbuf[start:], "empty rune literal or unescaped ' in rune literal")
+		} else {
+			l.errorf(CodeBadCharLiteral, pos, lc, l.idbuf[start:], "more than one character in rune literal")
+		}
+		valid = false
+	}
+	if !valid {
+		return TokIllegal, l.idbuf[start:]
+	}
+	return TokCharLit, l.idbuf[start:]
+}
+
+// readEscape consumes an escape sequence starting at the current backslash.
+// quote is the delimiter of the enclosing literal, which may be escaped.
+// Reports whether the escape sequence is valid. Invalid characters are not consumed.
+func (l *Lexer) readEscape(quote rune) bool {
+	pos, lc := l.Pos(), l.LineCol()
+	esc := len(l.idbuf)
+	l.consume() // Backslash.
+	// offending returns the escape sequence read so far including the current character.
+	offending := func() []byte {
+		b := l.idbuf[esc:len(l.idbuf):len(l.idbuf)] // Full slice expression so idbuf is not modified on append.
+		if !l.IsDone() {
+			b = utf8.AppendRune(b, l.ch)
+		}
+		return b
+	}
+	var n int
+	var base, max uint32
+	switch l.ch {
+	case 'a', 'b', 'f', 'n', 'r', 't', 'v', '\\', quote:
+		l.consume()
+		return true
+	case '0', '1', '2', '3', '4', '5', '6', '7':
+		n, base, max = 3, 8, 255
+	case 'x':
+		l.consume()
+		n, base, max = 2, 16, 255
+	case 'u':
+		l.consume()
+		n, base, max = 4, 16, unicode.MaxRune
+	case 'U':
+		l.consume()
+		n, base, max = 8, 16, unicode.MaxRune
+	default:
+		if l.IsDone() {
+			l.errorf(CodeBadEscape, pos, lc, offending(), "escape sequence not terminated")
+		} else {
+			l.errorf(CodeBadEscape, pos, lc, offending(), "unknown escape sequence %q", offending())
+		}
+		return false
+	}
+	var x uint32
+	for ; n > 0; n-- {
+		d := uint32(digitVal(l.ch))
+		if d >= base {
+			if l.IsDone() {
+				l.errorf(CodeBadEscape, pos, lc, offending(), "escape sequence not terminated")
+			} else {
+				l.errorf(CodeBadEscape, pos, lc, offending(), "illegal character %q in escape sequence", l.ch)
+			}
+			return false
+		}
+		x = x*base + d
+		l.consume()
+	}
+	if x > max || 0xD800 <= x && x < 0xE000 {
+		l.errorf(CodeBadEscape, pos, lc, l.idbuf[esc:], "escape sequence is invalid Unicode code point")
+		return false
+	}
+	return true
+}
+
+func digitVal(ch rune) int {
+	switch {
+	case isDigit(ch):
+		return int(ch - '0')
+	case 'a' <= lower(ch) && lower(ch) <= 'f':
+		return int(lower(ch) - 'a' + 10)
+	}
+	return 16 // Larger than any legal digit value.
+}
+
+var errNotQuoted = errors.New("literal is not a string or char literal")
+
+// AppendUnquote appends the decoded value of a string, raw string or char
+// literal to dst and returns the extended buffer. Quotes are removed and
+// escape sequences are replaced by the bytes they represent.
+// Carriage returns are discarded from raw strings as in Go.
+func AppendUnquote(dst []byte, tok Token, literal []byte) ([]byte, error) {
+	n := len(literal)
+	if n < 2 {
+		return dst, errNotQuoted
+	}
+	var quote byte
+	switch tok {
+	case TokStringLit:
+		quote = '"'
+	case TokCharLit:
+		quote = '\''
+	case TokRawStringLit:
+		quote = '`'
+	default:
+		return dst, errNotQuoted
+	}
+	if literal[0] != quote || literal[n-1] != quote {
+		return dst, errNotQuoted
+	}
+	body := literal[1 : n-1]
+	if tok == TokRawStringLit {
+		for _, c := range body {
+			if c != '\r' {
+				dst = append(dst, c)
+			}
+		}
+		return dst, nil
+	}
+	s := string(body)
+	for len(s) > 0 {
+		value, multibyte, tail, err := strconv.UnquoteChar(s, quote)
+		if err != nil {
+			return dst, err
+		}
+		s = tail
+		if tok == TokCharLit {
+			if len(s) > 0 {
+				return dst, errors.New("more than one character in rune literal")
+			}
+			return utf8.AppendRune(dst, value), nil
+		} else if value < utf8.RuneSelf || !multibyte {
+			dst = append(dst, byte(value))
+		} else {
+			dst = utf8.AppendRune(dst, value)
+		}
+	}
+	return dst, nil
+}
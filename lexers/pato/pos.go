@@ -36,12 +36,14 @@ func (lc LineCol) AppendString(b []byte) []byte {
 	return b
 }
 
-// Pos represents a byte offset in the source.
+// Pos represents a byte offset in the source. When lexing a file of a [FileSet]
+// it is the offset plus the file's base so that it identifies both file and offset.
 type Pos int
 
 // ToLineCol converts a byte offset to line:column.
 // Line and column are 1-indexed. Also returns the length of the line containing the offset.
 // aux is a scratch buffer used for reading; its size determines read chunk size (1024B recommended).
+// ToLineCol reads the source from the start on every call, use a [LineTable] for repeated conversions.
 func (pos Pos) ToLineCol(r io.ReaderAt, aux []byte) (line, col, lineLength int, err error) {
 	offset := int(pos)
 	if r == nil || offset < 0 {
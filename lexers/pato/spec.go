@@ -0,0 +1,198 @@
+package pato
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"unicode/utf8"
+)
+
+// Spec declares the lexical structure of a language: its keywords, operators,
+// comment syntax, literal kinds and identifier rules. A [Lexer] consumes its Spec
+// on Reset. Tokens not built into pato may be declared starting at [TokUser].
+// Lexers copy what they need from the Spec so modifying it does not affect lexing
+// in progress. A lexer compiles its Spec again only if [Lexer.Spec] points to a
+// different Spec on Reset, so set it to a modified copy to change the language.
+type Spec struct {
+	// Keywords lists identifiers that lex to a dedicated token.
+	Keywords []Symbol
+	// Operators lists operators and punctuation of up to peeklen+1 characters.
+	// Operators are matched by maximal munch. If "\n" is not an operator newlines are skipped as whitespace.
+	Operators []Symbol
+	// Comments configures the comment syntax. Comments are skipped unless [Lexer.KeepComments] is set.
+	Comments CommentSyntax
+	// Literals selects the literal kinds recognized.
+	Literals LiteralKind
+	// Identifiers selects the characters that make up identifiers.
+	Identifiers IdentRule
+}
+
+// Symbol associates the text of a keyword or operator with its token.
+type Symbol struct {
+	Text string
+	Tok  Token
+}
+
+// LiteralKind is a bitmask of literal kinds recognized by a [Spec].
+type LiteralKind uint8
+
+const (
+	LitInt       LiteralKind = 1 << iota // Integers: 42, 0xFF, 0o17, 0b1010 and 1_000.
+	LitFloat                             // Floating point numbers: 3.14, .5, 1e-9 and 0x1p-2. Requires LitInt.
+	LitString                            // Interpreted strings: "a\tb".
+	LitRawString                         // Raw strings: `a\tb`.
+	LitChar                              // Characters: 'a'.
+
+	LitAll = LitInt | LitFloat | LitString | LitRawString | LitChar
+)
+
+// IdentRule selects the characters that make up identifiers.
+type IdentRule uint8
+
+const (
+	// IdentASCII identifiers are made up of ASCII letters, digits and underscores.
+	IdentASCII IdentRule = iota
+	// IdentUnicode identifiers are defined by Unicode UAX #31 using the
+	// XID_Start and XID_Continue properties, i.e: résumé, 変数 or Δt. Underscores may start identifiers.
+	IdentUnicode
+)
+
+// DefaultSpec returns the built-in Go-like language with the keywords and operators
+// declared as Token constants. It has no comments configured. Each call returns
+// a new Spec which may be modified to derive a new language.
+func DefaultSpec() Spec {
+	return Spec{
+		Keywords:  defaultKeywords(),
+		Operators: defaultOperators(),
+		Literals:  LitAll,
+	}
+}
+
+func defaultKeywords() (kws []Symbol) {
+	for tok := keywordBeg + 1; tok < keywordEnd; tok++ {
+		kws = append(kws, Symbol{Text: tok.String(), Tok: tok})
+	}
+	return kws
+}
+
+func defaultOperators() []Symbol {
+	ops := []Symbol{{Text: "\n", Tok: TokNewline}}
+	for tok := TokLParen; tok <= TokSlash; tok++ {
+		ops = append(ops, Symbol{Text: tok.String(), Tok: tok})
+	}
+	for tok := TokPercent; tok <= TokPeriod; tok++ {
+		ops = append(ops, Symbol{Text: tok.String(), Tok: tok})
+	}
+	for tok := operatorBeg + 1; tok < operatorEnd; tok++ {
+		ops = append(ops, Symbol{Text: tok.String(), Tok: tok})
+	}
+	return ops
+}
+
+// compiledSpec holds the lookup tables built from a Spec and a copy of its
+// remaining fields so that lexing does not depend on the Spec after Reset.
+type compiledSpec struct {
+	src         *Spec // Spec compiled, only used to detect a change of Spec.
+	comments    CommentSyntax
+	literals    LiteralKind
+	identifiers IdentRule
+	builtin     bool                // Keywords of DefaultSpec, use perfect hash keyword lookup.
+	keywords    map[string]Token    // Keyword lookup for user specs.
+	operators   map[string]Token    // All operators, single and multi-character.
+	opStart     [utf8.RuneSelf]bool // ASCII characters that start an operator.
+	opStartNA   map[rune]bool       // Non-ASCII characters that start an operator.
+	newline     bool                // Newline is a token.
+}
+
+var defaultCompiled = mustCompile(nil)
+
+// mustCompile compiles spec, or the Spec of DefaultSpec if spec is nil.
+func mustCompile(spec *Spec) *compiledSpec {
+	src := spec
+	if src == nil {
+		def := DefaultSpec()
+		src = &def
+	}
+	cs, err := compileSpec(src)
+	if err != nil {
+		panic(err)
+	}
+	cs.src = spec
+	return cs
+}
+
+func compileSpec(spec *Spec) (*compiledSpec, error) {
+	if spec.Literals&LitFloat != 0 && spec.Literals&LitInt == 0 {
+		return nil, errors.New("spec LitFloat requires LitInt")
+	} else if err := spec.Comments.validate(); err != nil {
+		return nil, err
+	}
+	cs := &compiledSpec{
+		src: spec,
+		comments: CommentSyntax{
+			Line:   slices.Clone(spec.Comments.Line),
+			Block:  slices.Clone(spec.Comments.Block),
+			Nested: spec.Comments.Nested,
+		},
+		literals:    spec.Literals,
+		identifiers: spec.Identifiers,
+		builtin:     slices.Equal(spec.Keywords, defaultKeywords()),
+		operators:   make(map[string]Token, len(spec.Operators)),
+	}
+	for _, op := range spec.Operators {
+		n := utf8.RuneCountInString(op.Text)
+		if n == 0 || op.Tok == 0 {
+			return nil, fmt.Errorf("spec operator %q must be non-empty with defined token", op.Text)
+		} else if n > peeklen+1 {
+			return nil, fmt.Errorf("spec operator %q exceeds lexer lookahead of %d characters", op.Text, peeklen+1)
+		} else if _, dup := cs.operators[op.Text]; dup {
+			return nil, fmt.Errorf("duplicate spec operator %q", op.Text)
+		}
+		cs.operators[op.Text] = op.Tok
+		first, _ := utf8.DecodeRuneInString(op.Text)
+		if first < utf8.RuneSelf {
+			cs.opStart[first] = true
+		} else {
+			if cs.opStartNA == nil {
+				cs.opStartNA = make(map[rune]bool)
+			}
+			cs.opStartNA[first] = true
+		}
+		cs.newline = cs.newline || op.Text == "\n"
+	}
+	if !cs.builtin {
+		cs.keywords = make(map[string]Token, len(spec.Keywords))
+		for _, kw := range spec.Keywords {
+			if kw.Text == "" || kw.Tok == 0 {
+				return nil, fmt.Errorf("spec keyword %q must be non-empty with defined token", kw.Text)
+			} else if _, dup := cs.keywords[kw.Text]; dup {
+				return nil, fmt.Errorf("duplicate spec keyword %q", kw.Text)
+			}
+			cs.keywords[kw.Text] = kw.Tok
+		}
+	}
+	return cs, nil
+}
+
+// lookupKeyword returns the keyword token for ident or TokIDENT if ident is not a keyword.
+func (cs *compiledSpec) lookupKeyword(ident []byte) Token {
+	if cs.builtin {
+		return Lookup(string(ident)) // Should be optimized by compiler to not allocate.
+	}
+	if tok, ok := cs.keywords[string(ident)]; ok {
+		return tok
+	}
+	return TokIDENT
+}
+
+// isOperatorStart reports whether an operator starts with ch.
+func (cs *compiledSpec) isOperatorStart(ch rune) bool {
+	if ch < utf8.RuneSelf {
+		return ch >= 0 && cs.opStart[ch]
+	}
+	return cs.opStartNA[ch]
+}
+
+func (cs *compiledSpec) has(kind LiteralKind) bool {
+	return cs.literals&kind != 0
+}
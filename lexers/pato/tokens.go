@@ -1,8 +1,7 @@
 package pato
 
-import "fmt"
-
-//go:generate stringer -type=Token -linecomment -output stringers.go .
+//go:generate stringer -type=Token,ErrorCode -linecomment -output stringers.go .
+//go:generate go run ../../cmd/phashgen -type=Token -range=keywordBeg,keywordEnd -output=kwhash.go
 
 type Token uint
 
@@ -27,38 +26,72 @@ const (
 	TokIDENT  // <identifier>
 	TokEOF    // EOF
 
-	// Add keywords between keywordBeg and keywordEnd.
+	// Tokens added after the ones above are appended here so that
+	// the values of existing tokens do not change.
+	TokFloatLit     // <float literal>
+	TokStringLit    // <string literal>
+	TokRawStringLit // <raw string literal>
+	TokCharLit      // <char literal>
+	TokComment      // <comment>
+	// More single character tokens:
+	TokPercent   // %
+	TokAmpersand // &
+	TokPipe      // |
+	TokTilde     // ~
+	TokBang      // !
+	TokAssign    // =
+	TokLess      // <
+	TokGreater   // >
+	TokComma     // ,
+	TokSemicolon // ;
+	TokColon     // :
+	TokPeriod    // .
+
+	// Add multi-character operators between operatorBeg and operatorEnd.
+	operatorBeg
+	TokEq           // ==
+	TokNotEq        // !=
+	TokLessEq       // <=
+	TokGreaterEq    // >=
+	TokLogicalAnd   // &&
+	TokLogicalOr    // ||
+	TokShl          // <<
+	TokShr          // >>
+	TokAndNot       // &^
+	TokPow          // **
+	TokInc          // ++
+	TokDec          // --
+	TokDefine       // :=
+	TokArrow        // ->
+	TokLArrow       // <-
+	TokEllipsis     // ...
+	TokPlusAssign   // +=
+	TokMinusAssign  // -=
+	TokMulAssign    // *=
+	TokDivAssign    // /=
+	TokModAssign    // %=
+	TokXorAssign    // ^=
+	TokAndAssign    // &=
+	TokOrAssign     // |=
+	TokShlAssign    // <<=
+	TokShrAssign    // >>=
+	TokAndNotAssign // &^=
+	TokPowAssign    // **=
+	operatorEnd
+
+	// Add keywords between keywordBeg and keywordEnd and run go generate.
 	keywordBeg
 	TokIf   // if
 	TokElse // else
 	TokFor  // for
 	keywordEnd
-)
-
-var keywordMap [1 << 4]Token
-
-// kwhash is a perfect hash function for keywords.
-// It assumes that s has at least length 2.
-func kwhash(id string) uint {
-	// See perfect_hash_test.go for information on how to search for a
-	// perfect hash function in cases where bit shifts add too little entropy
-	// and multiplication is needed.
-	return (uint(id[0])<<4 ^ uint(id[1]) + uint(len(id))) & uint(len(keywordMap)-1)
-}
 
-func init() {
-	// populate keywordMap
-	for tok := keywordBeg + 1; tok < keywordEnd; tok++ {
-		h := kwhash(tok.String())
-		if keywordMap[h] != 0 {
-			panic(fmt.Sprintf("imperfect hash at %0x %s collides with %s (%d/%d ok)", h, keywordMap[h].String(), tok.String(), tok-keywordBeg-1, keywordEnd-keywordBeg-1))
-		}
-		keywordMap[h] = tok
-	}
-}
+	// TokUser is the first token value free for use by tokens declared in a user [Spec].
+	TokUser
+)
 
 func IsKeyword(s string) bool {
-	if len(s) < 2 {
+	if len(s) < kwhashMinLen {
 		return false
 	}
 	tok := keywordMap[kwhash(s)]
@@ -66,7 +99,7 @@ func IsKeyword(s string) bool {
 }
 
 func Lookup(s string) Token {
-	if len(s) < 2 {
+	if len(s) < kwhashMinLen {
 		return TokIDENT
 	}
 	tok := keywordMap[kwhash(s)]
@@ -76,6 +109,12 @@ func Lookup(s string) Token {
 	return TokIDENT
 }
 
+// LookupOperator returns the [DefaultSpec] operator token for s, which may be a single or
+// multi-character operator. Returns TokUndefined if s is not an operator.
+func LookupOperator(s string) Token {
+	return defaultCompiled.operators[s]
+}
+
 func LookupSingleChar(r rune) (tok Token) {
 	switch r {
 	// Single-character token switch branch.
@@ -105,6 +144,30 @@ func LookupSingleChar(r rune) (tok Token) {
 		tok = TokAsterisk
 	case '^':
 		tok = TokHat
+	case '%':
+		tok = TokPercent
+	case '&':
+		tok = TokAmpersand
+	case '|':
+		tok = TokPipe
+	case '~':
+		tok = TokTilde
+	case '!':
+		tok = TokBang
+	case '=':
+		tok = TokAssign
+	case '<':
+		tok = TokLess
+	case '>':
+		tok = TokGreater
+	case ',':
+		tok = TokComma
+	case ';':
+		tok = TokSemicolon
+	case ':':
+		tok = TokColon
+	case '.':
+		tok = TokPeriod
 	default:
 		tok = TokIDENT
 	}
@@ -0,0 +1,207 @@
+// Package deriv differentiates MATLAB expressions parsed by [parse.Parse] with
+// respect to the elements of a vector variable, as needed for the linearization
+// of state-space models f(X,U) around an operating point:
+//
+//	x, err := parse.Parse(lexer.Items())
+//	...
+//	A, err := deriv.Jacobian(x, "X", 12) // ∂f/∂X.
+//	...
+//	B, err := deriv.Jacobian(x, "U", 4) // ∂f/∂U.
+//	...
+//	fmt.Println(parse.Format(A))
+//
+// Derivatives are expression trees which may be printed back to MATLAB syntax
+// with [parse.Format], evaluated with package eval or compiled with package vm.
+// They are simplified as they are built, see [Simplify].
+package deriv
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/soypat/lexer/lexers/pike/parse"
+)
+
+// Error is a differentiation error positioned in the input of the expression.
+type Error struct {
+	Pos, End int // Byte offsets of the expression that could not be differentiated.
+	Msg      string
+}
+
+// Error returns the error formatted as "offset: message".
+func (e *Error) Error() string {
+	return strconv.Itoa(e.Pos) + ": " + e.Msg
+}
+
+func errorf(x parse.Expr, format string, args ...any) error {
+	return &Error{Pos: x.Pos(), End: x.End(), Msg: fmt.Sprintf(format, args...)}
+}
+
+// Jacobian returns the matrix of partial derivatives of the elements of f
+// with respect to the n elements of vector variable v, so that element (i,j)
+// is ∂f(i)/∂v(j). The elements of f are taken in column-major order.
+func Jacobian(f parse.Expr, v string, n int) (*parse.Matrix, error) {
+	var elems []parse.Expr
+	if m, ok := f.(*parse.Matrix); ok && len(m.Rows) > 0 {
+		cols := len(m.Rows[0])
+		for i, row := range m.Rows {
+			if len(row) != cols {
+				return nil, errorf(row[0], "row %d has %d elements, want %d", i+1, len(row), cols)
+			}
+		}
+		for j := range cols {
+			for _, row := range m.Rows {
+				elems = append(elems, row[j])
+			}
+		}
+	} else if !ok {
+		elems = []parse.Expr{f}
+	}
+	J := &parse.Matrix{Rows: make([][]parse.Expr, len(elems))}
+	for i, elem := range elems {
+		J.Rows[i] = make([]parse.Expr, n)
+		for j := range n {
+			d, err := Derivative(elem, v, j+1)
+			if err != nil {
+				return nil, err
+			}
+			J.Rows[i][j] = d
+		}
+	}
+	return J, nil
+}
+
+// Derivative returns the simplified partial derivative of scalar expression x
+// with respect to element k of vector variable v, indexed from 1 as in v(k).
+// Supported are the arithmetic operators and the functions sin, cos, tan, exp,
+// log and sqrt. Other functions may be applied to expressions independent of v.
+func Derivative(x parse.Expr, v string, k int) (parse.Expr, error) {
+	d := differ{v: v, k: k}
+	return d.diff(x)
+}
+
+type differ struct {
+	v string
+	k int
+}
+
+func (d *differ) isVar(id *parse.Ident) bool {
+	return id.Name == d.v && id.Kind != parse.IdentFunc
+}
+
+func (d *differ) diff(x parse.Expr) (parse.Expr, error) {
+	switch x := x.(type) {
+	case *parse.Number:
+		return number(0), nil
+	case *parse.Paren:
+		return d.diff(x.X)
+	case *parse.Ident:
+		if d.isVar(x) {
+			return nil, errorf(x, "vector %s must be indexed", x.Name)
+		}
+		return number(0), nil // Constant such as pi or another variable.
+	case *parse.Index:
+		return d.index(x, x.X, x.Args)
+	case *parse.Call:
+		if d.isVar(x.Func) {
+			return d.index(x, x.Func, x.Args) // Undefined identifier at lexing.
+		}
+		return d.call(x)
+	case *parse.Unary:
+		dx, err := d.diff(x.X)
+		if err != nil || x.Op == '+' {
+			return dx, err
+		}
+		return neg(dx), nil
+	case *parse.Binary:
+		dx, err := d.diff(x.X)
+		if err != nil {
+			return nil, err
+		}
+		dy, err := d.diff(x.Y)
+		if err != nil {
+			return nil, err
+		}
+		a, b := unparen(x.X), unparen(x.Y)
+		switch x.Op {
+		case '+':
+			return add(dx, dy), nil
+		case '-':
+			return sub(dx, dy), nil
+		case '*':
+			return add(mul(dx, b), mul(a, dy)), nil // Product rule.
+		case '/':
+			if isZero(dy) {
+				return div(dx, b), nil
+			}
+			return div(sub(mul(dx, b), mul(a, dy)), pow(b, number(2))), nil // Quotient rule.
+		case '^':
+			if isZero(dy) {
+				return mul(mul(b, pow(a, sub(b, number(1)))), dx), nil // Power rule.
+			}
+			// d(a^b) = a^b * (b'*log(a) + b*a'/a).
+			return mul(pow(a, b), add(mul(dy, call("log", a)), div(mul(b, dx), a))), nil
+		}
+		return nil, errorf(x, "operator %c not supported", x.Op)
+	case *parse.Matrix:
+		return nil, errorf(x, "derivative of matrix not supported, see Jacobian")
+	case *parse.Range, *parse.Colon:
+		return nil, errorf(x, "derivative of range not supported")
+	}
+	return nil, errorf(x, "unsupported expression %T", x)
+}
+
+// index returns the derivative of an element of a variable, 1 if it is element k of v.
+func (d *differ) index(x parse.Expr, id *parse.Ident, args []parse.Expr) (parse.Expr, error) {
+	if !d.isVar(id) {
+		return number(0), nil
+	} else if len(args) != 1 {
+		return nil, errorf(x, "index of %s must have 1 subscript, got %d", id.Name, len(args))
+	}
+	sub, ok := constant(Simplify(args[0]))
+	if !ok || sub < 1 || sub != math.Trunc(sub) {
+		return nil, errorf(args[0], "index of %s must be a constant positive integer", id.Name)
+	} else if int(sub) == d.k {
+		return number(1), nil
+	}
+	return number(0), nil
+}
+
+// call applies the chain rule to a function call.
+func (d *differ) call(x *parse.Call) (parse.Expr, error) {
+	dargs := make([]parse.Expr, len(x.Args))
+	independent := true
+	for i, arg := range x.Args {
+		da, err := d.diff(arg)
+		if err != nil {
+			return nil, err
+		}
+		dargs[i] = da
+		independent = independent && isZero(da)
+	}
+	if independent {
+		return number(0), nil
+	} else if len(x.Args) != 1 {
+		return nil, errorf(x, "derivative of %s not supported", x.Func.Name)
+	}
+	a, da := unparen(x.Args[0]), dargs[0]
+	var df parse.Expr
+	switch x.Func.Name {
+	case "sin":
+		df = call("cos", a)
+	case "cos":
+		df = neg(call("sin", a))
+	case "tan":
+		df = div(number(1), pow(call("cos", a), number(2)))
+	case "exp":
+		df = call("exp", a)
+	case "log":
+		return div(da, a), nil
+	case "sqrt":
+		return div(da, mul(number(2), call("sqrt", a))), nil
+	default:
+		return nil, errorf(x, "derivative of %s not supported", x.Func.Name)
+	}
+	return mul(df, da), nil
+}
@@ -0,0 +1,180 @@
+package deriv
+
+import (
+	"errors"
+	"math"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/soypat/lexer/lexers/pike"
+	"github.com/soypat/lexer/lexers/pike/eval"
+	"github.com/soypat/lexer/lexers/pike/parse"
+	"github.com/soypat/lexer/lexers/pike/vm"
+)
+
+var (
+	testX = []float64{1, 2, 3, 0.1, -0.2, 0.3, 7, 8, 9, 10, 11, 12}
+	testU = []float64{1.5, 0.25, -0.5, 2}
+)
+
+func parseString(t *testing.T, input string) parse.Expr {
+	t.Helper()
+	l, err := pike.NewLexer("test.m", input, pike.Options{Variables: []string{"X", "U"}, Builtins: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	x, err := parse.Parse(l.Items())
+	if err != nil {
+		t.Fatalf("%q: %v", input, err)
+	}
+	return x
+}
+
+func TestDerivative(t *testing.T) {
+	tests := []struct {
+		input string
+		k     int // Element of X.
+		want  string
+	}{
+		{"X(1)", 1, "1"},
+		{"X(1)", 2, "0"},
+		{"U(1)*X(2)", 2, "U(1)"},
+		{"X(1)^2", 1, "2*X(1)"},
+		{"X(1)^3/3", 1, "X(1)^2"},
+		{"3*X(1)^-1", 1, "-3*X(1)^-2"},
+		{"(X(1)+2)*(X(1)-2)", 1, "X(1)-2+X(1)+2"},
+		{"sin(X(1))*X(2)", 1, "cos(X(1))*X(2)"},
+		{"sin(X(1))*X(2)", 2, "sin(X(1))"},
+		{"cos(2*X(1))", 1, "-2*sin(2*X(1))"},
+		{"exp(X(1)*X(2))", 1, "exp(X(1)*X(2))*X(2)"},
+		{"log(X(1))", 1, "1/X(1)"},
+		{"sqrt(X(1))", 1, "1/(2*sqrt(X(1)))"},
+		{"tan(X(1))", 1, "1/cos(X(1))^2"},
+		{"1/X(1)", 1, "-1/X(1)^2"},
+		{"X(2)/X(1)", 2, "1/X(1)"},
+		{"2^X(1)", 1, "2^X(1)*log(2)"},
+		{"-(X(1)-X(2))", 1, "-1"},
+		{"X(1)-X(1)*1", 1, "0"},
+		{"atan2(U(1),U(2))*X(1)", 1, "atan2(U(1),U(2))"},
+		{"979/100+X(2)", 1, "0"},
+	}
+	for _, test := range tests {
+		d, err := Derivative(parseString(t, test.input), "X", test.k)
+		if err != nil {
+			t.Errorf("%q: %v", test.input, err)
+			continue
+		}
+		if got := parse.Format(d); got != test.want {
+			t.Errorf("d(%s)/dX(%d): got %s, want %s", test.input, test.k, got, test.want)
+		}
+	}
+}
+
+func TestSimplify(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"0+X(1)*1", "X(1)"},
+		{"(X(1))^1-0", "X(1)"},
+		{"-(-X(1))", "X(1)"},
+		{"X(1)+-X(2)", "X(1)-X(2)"},
+		{"2*(3*X(1))", "6*X(1)"},
+		{"X(1)*2", "2*X(1)"},
+		{"(2+3)*X(1)/(X(1))", "5"},
+		{"979/100", "979/100"},
+		{"(-X(1))*X(2)", "-X(1)*X(2)"},
+		{"[0*X(1),X(2)^0;1+1,(X(3))]", "[0,1;2,X(3)]"},
+	}
+	for _, test := range tests {
+		if got := parse.Format(Simplify(parseString(t, test.input))); got != test.want {
+			t.Errorf("%q: got %s, want %s", test.input, got, test.want)
+		}
+	}
+}
+
+func TestDerivativeErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		pos   int
+	}{
+		{"X*2", 0},
+		{"X(U(1))", 2},
+		{"X(1,1)", 0},
+		{"abs(X(1))", 0},
+		{"2*atan2(X(1),1)", 2},
+		{"[X(1),X(2)]", 0},
+		{"X(1:2)", 2},
+	}
+	for _, test := range tests {
+		_, err := Derivative(parseString(t, test.input), "X", 1)
+		var derr *Error
+		if !errors.As(err, &derr) {
+			t.Errorf("%q: expected *Error, got %v", test.input, err)
+		} else if derr.Pos != test.pos {
+			t.Errorf("%q: got error at %d, want %d: %v", test.input, derr.Pos, test.pos, err)
+		}
+	}
+}
+
+// TestJacobianDynamics compares the Jacobians of the example's state derivative
+// to central finite differences.
+func TestJacobianDynamics(t *testing.T) {
+	b, err := os.ReadFile("../vm/testdata/dynamics.m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := parseString(t, strings.TrimSpace(string(b)))
+	fprog, err := vm.Compile(f, "X", "U")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range []struct {
+		name string
+		vec  []float64
+	}{{"X", testX}, {"U", testU}} {
+		J, err := Jacobian(f, v.name, len(v.vec))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(J.Rows) != fprog.NumOut() || len(J.Rows[0]) != len(v.vec) {
+			t.Fatalf("d/d%s: got %dx%d Jacobian", v.name, len(J.Rows), len(J.Rows[0]))
+		}
+		jprog, err := vm.Compile(J, "X", "U")
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := make([]float64, jprog.NumOut())
+		if err := jprog.Eval(testX, testU, got); err != nil {
+			t.Fatal(err)
+		}
+		// Printed Jacobian evaluates to the same values.
+		printed := parseString(t, parse.Format(J))
+		pv, err := eval.Eval(printed, &eval.Env{Vars: map[string][]float64{"X": testX, "U": testU}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		rows := len(J.Rows)
+		fplus, fminus := make([]float64, rows), make([]float64, rows)
+		for j := range v.vec {
+			const h = 1e-6
+			orig := v.vec[j]
+			v.vec[j] = orig + h
+			fprog.Eval(testX, testU, fplus)
+			v.vec[j] = orig - h
+			fprog.Eval(testX, testU, fminus)
+			v.vec[j] = orig
+			for i := range rows {
+				want := (fplus[i] - fminus[i]) / (2 * h)
+				g := got[j*rows+i] // Column-major.
+				if math.Abs(g-want) > 1e-6*math.Max(1, math.Abs(want)) {
+					t.Errorf("d f(%d)/d%s(%d): got %v, finite difference %v", i+1, v.name, j+1, g, want)
+				}
+				if p := real(pv.Data[j*rows+i]); math.Abs(p-g) > 1e-12*math.Max(1, math.Abs(g)) {
+					t.Errorf("d f(%d)/d%s(%d): printed Jacobian evaluates to %v, want %v", i+1, v.name, j+1, p, g)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,314 @@
+package deriv
+
+import (
+	"math"
+	"strconv"
+
+	"github.com/soypat/lexer/lexers/pike/parse"
+)
+
+// Simplify returns x rebuilt with algebraic simplifications: parentheses are removed,
+// identities such as 0+a, 1*a, a^1 and a-a are reduced, negations are moved outwards
+// and operations on constants are folded where the result is exact.
+// Parentheses are added back where needed by [parse.Format].
+func Simplify(x parse.Expr) parse.Expr {
+	switch x := x.(type) {
+	case *parse.Paren:
+		return Simplify(x.X)
+	case *parse.Unary:
+		if x.Op == '-' {
+			return neg(Simplify(x.X))
+		}
+		return Simplify(x.X)
+	case *parse.Binary:
+		a, b := Simplify(x.X), Simplify(x.Y)
+		switch x.Op {
+		case '+':
+			return add(a, b)
+		case '-':
+			return sub(a, b)
+		case '*':
+			return mul(a, b)
+		case '/':
+			return div(a, b)
+		case '^':
+			return pow(a, b)
+		}
+	case *parse.Call:
+		return &parse.Call{Func: x.Func, Lparen: x.Lparen, Args: simplifyAll(x.Args), Rparen: x.Rparen}
+	case *parse.Index:
+		return &parse.Index{X: x.X, Lparen: x.Lparen, Args: simplifyAll(x.Args), Rparen: x.Rparen}
+	case *parse.Range:
+		r := &parse.Range{Start: Simplify(x.Start), Stop: Simplify(x.Stop)}
+		if x.Step != nil {
+			r.Step = Simplify(x.Step)
+		}
+		return r
+	case *parse.Matrix:
+		m := &parse.Matrix{Lbrack: x.Lbrack, Rows: make([][]parse.Expr, len(x.Rows)), Rbrack: x.Rbrack}
+		for i, row := range x.Rows {
+			m.Rows[i] = simplifyAll(row)
+		}
+		return m
+	}
+	return x
+}
+
+func simplifyAll(xs []parse.Expr) []parse.Expr {
+	s := make([]parse.Expr, len(xs))
+	for i, x := range xs {
+		s[i] = Simplify(x)
+	}
+	return s
+}
+
+func unparen(x parse.Expr) parse.Expr {
+	for {
+		p, ok := x.(*parse.Paren)
+		if !ok {
+			return x
+		}
+		x = p.X
+	}
+}
+
+// number returns the expression of f, a negation if f is negative.
+func number(f float64) parse.Expr {
+	if f < 0 {
+		return &parse.Unary{Op: '-', X: number(-f)}
+	}
+	return &parse.Number{Value: strconv.FormatFloat(f, 'g', -1, 64)}
+}
+
+// constant returns the value of x if it is a real number or a negated one.
+func constant(x parse.Expr) (float64, bool) {
+	switch x := x.(type) {
+	case *parse.Number:
+		if x.Imag {
+			return 0, false
+		}
+		f, err := strconv.ParseFloat(x.Value, 64)
+		return f, err == nil
+	case *parse.Unary:
+		f, ok := constant(x.X)
+		if x.Op == '-' {
+			f = -f
+		}
+		return f, ok
+	case *parse.Paren:
+		return constant(x.X)
+	}
+	return 0, false
+}
+
+func isConst(x parse.Expr, f float64) bool {
+	c, ok := constant(x)
+	return ok && c == f
+}
+
+func isZero(x parse.Expr) bool { return isConst(x, 0) }
+func isOne(x parse.Expr) bool  { return isConst(x, 1) }
+
+// fold returns the constant result of op on a and b if both are constants
+// and the result is exact, i.e: an integer.
+func fold(a, b parse.Expr, op func(x, y float64) float64) (parse.Expr, bool) {
+	x, ok := constant(a)
+	if !ok {
+		return nil, false
+	}
+	y, ok := constant(b)
+	if !ok {
+		return nil, false
+	}
+	r := op(x, y)
+	if r != math.Trunc(r) || math.Abs(r) > 1<<53 {
+		return nil, false
+	}
+	return number(r), true
+}
+
+// negated returns the operand of a negation, or the product or quotient
+// of a negated first factor, -a*b, without the negation.
+func negated(x parse.Expr) (parse.Expr, bool) {
+	switch x := x.(type) {
+	case *parse.Unary:
+		return x.X, x.Op == '-'
+	case *parse.Binary:
+		if a, ok := negated(x.X); ok && (x.Op == '*' || x.Op == '/') {
+			return &parse.Binary{X: a, Op: x.Op, Y: x.Y}, true
+		}
+	}
+	return nil, false
+}
+
+func call(name string, arg parse.Expr) parse.Expr {
+	return &parse.Call{Func: &parse.Ident{Name: name, Kind: parse.IdentFunc}, Args: []parse.Expr{arg}}
+}
+
+func neg(a parse.Expr) parse.Expr {
+	if c, ok := constant(a); ok {
+		return number(-c + 0) // No negative zero.
+	} else if x, ok := negated(a); ok {
+		return x
+	}
+	if b, ok := a.(*parse.Binary); ok {
+		switch b.Op {
+		case '-':
+			return sub(b.Y, b.X)
+		case '*', '/':
+			return &parse.Binary{X: neg(b.X), Op: b.Op, Y: b.Y} // -a*b rather than -(a*b).
+		}
+	}
+	return &parse.Unary{Op: '-', X: a}
+}
+
+func add(a, b parse.Expr) parse.Expr {
+	if r, ok := fold(a, b, func(x, y float64) float64 { return x + y }); ok {
+		return r
+	} else if isZero(a) {
+		return b
+	} else if isZero(b) {
+		return a
+	} else if y, ok := negated(b); ok {
+		return sub(a, y)
+	} else if x, ok := negated(a); ok {
+		return sub(b, x)
+	}
+	if bb, ok := b.(*parse.Binary); ok && (bb.Op == '+' || bb.Op == '-') {
+		return binary(add(a, bb.X), bb.Op, bb.Y) // a+(b+c) is a+b+c.
+	}
+	return &parse.Binary{X: a, Op: '+', Y: b}
+}
+
+func sub(a, b parse.Expr) parse.Expr {
+	if r, ok := fold(a, b, func(x, y float64) float64 { return x - y }); ok {
+		return r
+	} else if isZero(b) {
+		return a
+	} else if isZero(a) {
+		return neg(b)
+	} else if equal(a, b) {
+		return number(0)
+	} else if y, ok := negated(b); ok {
+		return add(a, y)
+	}
+	if bb, ok := b.(*parse.Binary); ok && (bb.Op == '+' || bb.Op == '-') {
+		op := byte('-') // a-(b+c) is a-b-c and a-(b-c) is a-b+c.
+		if bb.Op == '-' {
+			op = '+'
+		}
+		return binary(sub(a, bb.X), op, bb.Y)
+	}
+	return &parse.Binary{X: a, Op: '-', Y: b}
+}
+
+func mul(a, b parse.Expr) parse.Expr {
+	if r, ok := fold(a, b, func(x, y float64) float64 { return x * y }); ok {
+		return r
+	} else if isZero(a) || isZero(b) {
+		return number(0)
+	} else if isOne(a) {
+		return b
+	} else if isOne(b) {
+		return a
+	} else if x, ok := negated(a); ok {
+		return neg(mul(x, b))
+	} else if y, ok := negated(b); ok {
+		return neg(mul(a, y))
+	}
+	_, aconst := constant(a)
+	if _, bconst := constant(b); bconst && !aconst {
+		return mul(b, a) // Constant factors first, as in 2*x.
+	}
+	if bb, ok := b.(*parse.Binary); ok && aconst {
+		if r, ok := fold(a, bb.X, func(x, y float64) float64 { return x * y }); ok && bb.Op == '*' {
+			return mul(r, bb.Y) // 2*(3*x) is 6*x.
+		}
+	}
+	return &parse.Binary{X: a, Op: '*', Y: b}
+}
+
+func div(a, b parse.Expr) parse.Expr {
+	if r, ok := fold(a, b, func(x, y float64) float64 { return x / y }); ok {
+		return r
+	} else if isZero(a) {
+		return number(0)
+	} else if isOne(b) {
+		return a
+	} else if equal(a, b) {
+		return number(1)
+	} else if x, ok := negated(a); ok {
+		return neg(div(x, b))
+	} else if y, ok := negated(b); ok {
+		return neg(div(a, y))
+	}
+	if ab, ok := a.(*parse.Binary); ok && ab.Op == '*' {
+		if equal(ab.Y, b) {
+			return ab.X // a*b/b is a.
+		} else if equal(ab.X, b) {
+			return ab.Y
+		} else if r, ok := fold(ab.X, b, func(x, y float64) float64 { return x / y }); ok {
+			return mul(r, ab.Y) // 6*x/3 is 2*x.
+		}
+	}
+	return &parse.Binary{X: a, Op: '/', Y: b}
+}
+
+func pow(a, b parse.Expr) parse.Expr {
+	if r, ok := fold(a, b, math.Pow); ok {
+		return r
+	} else if isZero(b) || isOne(a) {
+		return number(1)
+	} else if isOne(b) {
+		return a
+	}
+	return &parse.Binary{X: a, Op: '^', Y: b}
+}
+
+func binary(a parse.Expr, op byte, b parse.Expr) parse.Expr {
+	if op == '+' {
+		return add(a, b)
+	}
+	return sub(a, b)
+}
+
+// equal reports whether a and b are the same expression.
+func equal(a, b parse.Expr) bool {
+	a, b = unparen(a), unparen(b)
+	if a == b {
+		return true
+	}
+	switch a := a.(type) {
+	case *parse.Number:
+		b, ok := b.(*parse.Number)
+		return ok && a.Value == b.Value
+	case *parse.Ident:
+		b, ok := b.(*parse.Ident)
+		return ok && a.Name == b.Name
+	case *parse.Unary:
+		b, ok := b.(*parse.Unary)
+		return ok && a.Op == b.Op && equal(a.X, b.X)
+	case *parse.Binary:
+		b, ok := b.(*parse.Binary)
+		return ok && a.Op == b.Op && equal(a.X, b.X) && equal(a.Y, b.Y)
+	case *parse.Call:
+		b, ok := b.(*parse.Call)
+		return ok && a.Func.Name == b.Func.Name && equalAll(a.Args, b.Args)
+	case *parse.Index:
+		b, ok := b.(*parse.Index)
+		return ok && a.X.Name == b.X.Name && equalAll(a.Args, b.Args)
+	}
+	return false
+}
+
+func equalAll(a, b []parse.Expr) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
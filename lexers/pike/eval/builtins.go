@@ -0,0 +1,512 @@
+package eval
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/cmplx"
+)
+
+// builtins implements the built-in functions of pike. Arity is checked by
+// the caller against the catalogue of pike.LookupFunc.
+var builtins = map[string]Func{
+	"sin":   realOrComplex(math.Sin, cmplx.Sin, nil),
+	"cos":   realOrComplex(math.Cos, cmplx.Cos, nil),
+	"tan":   realOrComplex(math.Tan, cmplx.Tan, nil),
+	"asin":  realOrComplex(math.Asin, cmplx.Asin, inUnit),
+	"acos":  realOrComplex(math.Acos, cmplx.Acos, inUnit),
+	"atan":  realOrComplex(math.Atan, cmplx.Atan, nil),
+	"sec":   ElementWise(func(z complex128) complex128 { return 1 / cosine(z) }),
+	"csc":   ElementWise(func(z complex128) complex128 { return 1 / sine(z) }),
+	"cot":   ElementWise(func(z complex128) complex128 { return cosine(z) / sine(z) }),
+	"sinh":  realOrComplex(math.Sinh, cmplx.Sinh, nil),
+	"cosh":  realOrComplex(math.Cosh, cmplx.Cosh, nil),
+	"tanh":  realOrComplex(math.Tanh, cmplx.Tanh, nil),
+	"asinh": realOrComplex(math.Asinh, cmplx.Asinh, nil),
+	"acosh": realOrComplex(math.Acosh, cmplx.Acosh, func(x float64) bool { return x >= 1 }),
+	"atanh": realOrComplex(math.Atanh, cmplx.Atanh, inUnit),
+	"exp":   realOrComplex(math.Exp, cmplx.Exp, nil),
+	"expm1": realOrComplex(math.Expm1, func(z complex128) complex128 { return cmplx.Exp(z) - 1 }, nil),
+	"log":   realOrComplex(math.Log, cmplx.Log, nonNegative),
+	"log1p": realOrComplex(math.Log1p, func(z complex128) complex128 { return cmplx.Log(1 + z) }, func(x float64) bool { return x >= -1 }),
+	"log2":  realOrComplex(math.Log2, func(z complex128) complex128 { return cmplx.Log(z) / math.Ln2 }, nonNegative),
+	"log10": realOrComplex(math.Log10, cmplx.Log10, nonNegative),
+	"sqrt":  realOrComplex(math.Sqrt, cmplx.Sqrt, nonNegative),
+	"abs":   ElementWise(func(z complex128) complex128 { return complex(cmplx.Abs(z), 0) }),
+	"sign": ElementWise(func(z complex128) complex128 {
+		if z == 0 {
+			return 0
+		} else if imag(z) == 0 {
+			return complex(math.Copysign(1, real(z)), 0)
+		}
+		return z / complex(cmplx.Abs(z), 0)
+	}),
+	"floor": parts(math.Floor),
+	"ceil":  parts(math.Ceil),
+	"fix":   parts(math.Trunc),
+	"round": roundFunc,
+	"real":  ElementWise(func(z complex128) complex128 { return complex(real(z), 0) }),
+	"imag":  ElementWise(func(z complex128) complex128 { return complex(imag(z), 0) }),
+	"conj":  ElementWise(cmplx.Conj),
+	"angle": ElementWise(func(z complex128) complex128 { return complex(cmplx.Phase(z), 0) }),
+	"gamma": realOnly1(math.Gamma),
+	"erf":   realOnly1(math.Erf),
+	"erfc":  realOnly1(math.Erfc),
+
+	"atan2":   realOnly2(math.Atan2),
+	"hypot":   realOnly2(math.Hypot),
+	"mod":     realOnly2(func(x, y float64) float64 { return remainder(x, y, math.Floor) }),
+	"rem":     realOnly2(func(x, y float64) float64 { return remainder(x, y, math.Trunc) }),
+	"nthroot": realOnly2(nthroot),
+	"power":   elementWise2(func(a, b complex128) complex128 { return scalarOp('^', a, b) }),
+
+	"min":  minMaxFunc(func(a, b float64) bool { return a < b }),
+	"max":  minMaxFunc(func(a, b float64) bool { return a > b }),
+	"sum":  reduceFunc(func(v []complex128) (complex128, error) { return sum(v), nil }),
+	"prod": reduceFunc(prod),
+	"mean": reduceFunc(func(v []complex128) (complex128, error) {
+		return sum(v) / complex(float64(len(v)), 0), nil
+	}),
+	"norm":  normFunc,
+	"dot":   dotFunc,
+	"cross": crossFunc,
+
+	"transpose": transposeFunc,
+	"inv":       invFunc,
+	"det":       detFunc,
+	"trace":     traceFunc,
+	"size":      sizeFunc,
+	"numel":     func(args []Value) (Value, error) { return Scalar(complex(float64(len(args[0].Data)), 0)), nil },
+	"length": func(args []Value) (Value, error) {
+		n := 0
+		if len(args[0].Data) > 0 {
+			n = max(args[0].Rows, args[0].Cols)
+		}
+		return Scalar(complex(float64(n), 0)), nil
+	},
+	"zeros": filled(func(i, j int) complex128 { return 0 }),
+	"ones":  filled(func(i, j int) complex128 { return 1 }),
+	"eye": filled(func(i, j int) complex128 {
+		if i == j {
+			return 1
+		}
+		return 0
+	}),
+	"pi":  filled(func(i, j int) complex128 { return math.Pi }),
+	"eps": filled(func(i, j int) complex128 { return 0x1p-52 }),
+}
+
+func inUnit(x float64) bool      { return x >= -1 && x <= 1 }
+func nonNegative(x float64) bool { return x >= 0 }
+
+func sine(z complex128) complex128   { return realOrComplexElem(math.Sin, cmplx.Sin, nil, z) }
+func cosine(z complex128) complex128 { return realOrComplexElem(math.Cos, cmplx.Cos, nil, z) }
+
+// realOrComplex returns an element-wise function computed with real arithmetic
+// for real arguments in domain, or all real arguments if domain is nil,
+// and with complex arithmetic otherwise, as sqrt(-1) is 1i in MATLAB.
+func realOrComplex(fr func(float64) float64, fc func(complex128) complex128, domain func(float64) bool) Func {
+	return ElementWise(func(z complex128) complex128 { return realOrComplexElem(fr, fc, domain, z) })
+}
+
+func realOrComplexElem(fr func(float64) float64, fc func(complex128) complex128, domain func(float64) bool, z complex128) complex128 {
+	if imag(z) == 0 && (domain == nil || domain(real(z))) {
+		return complex(fr(real(z)), 0)
+	}
+	return fc(z)
+}
+
+// parts applies f to the real and imaginary parts of each element, as floor and ceil do in MATLAB.
+func parts(f func(float64) float64) Func {
+	return ElementWise(func(z complex128) complex128 { return complex(f(real(z)), f(imag(z))) })
+}
+
+var errComplexArg = errors.New("complex argument not supported")
+
+func realOnly1(f func(float64) float64) Func {
+	return func(args []Value) (Value, error) {
+		if !args[0].IsReal() {
+			return Value{}, errComplexArg
+		}
+		return mapValue(args[0], func(z complex128) complex128 { return complex(f(real(z)), 0) }), nil
+	}
+}
+
+func realOnly2(f func(x, y float64) float64) Func {
+	fc := func(a, b complex128) complex128 { return complex(f(real(a), real(b)), 0) }
+	return func(args []Value) (Value, error) {
+		if !args[0].IsReal() || !args[1].IsReal() {
+			return Value{}, errComplexArg
+		}
+		return elementWise2(fc)(args)
+	}
+}
+
+// elementWise2 returns a function applying f to the elements of two arguments of the same size, or of a scalar and a matrix.
+func elementWise2(f func(a, b complex128) complex128) Func {
+	return func(args []Value) (Value, error) {
+		a, b := args[0], args[1]
+		switch {
+		case a.IsScalar():
+			return mapValue(b, func(z complex128) complex128 { return f(a.Data[0], z) }), nil
+		case b.IsScalar():
+			return mapValue(a, func(z complex128) complex128 { return f(z, b.Data[0]) }), nil
+		case a.Rows != b.Rows || a.Cols != b.Cols:
+			return Value{}, fmt.Errorf("arguments of size %dx%d and %dx%d differ", a.Rows, a.Cols, b.Rows, b.Cols)
+		}
+		r := newMatrix(a.Rows, a.Cols)
+		for i := range r.Data {
+			r.Data[i] = f(a.Data[i], b.Data[i])
+		}
+		return r, nil
+	}
+}
+
+// remainder returns x - round(x/y)*y, which is x if y is zero as in MATLAB.
+func remainder(x, y float64, round func(float64) float64) float64 {
+	if y == 0 {
+		return x
+	}
+	return x - round(x/y)*y
+}
+
+// nthroot returns the real nth root of x, negative for negative x and odd n.
+func nthroot(x, n float64) float64 {
+	if x < 0 && math.Mod(n, 2) != 1 && math.Mod(n, 2) != -1 {
+		return math.NaN()
+	}
+	y := math.Copysign(math.Pow(math.Abs(x), 1/n), x)
+	if y != 0 && !math.IsInf(y, 0) && !math.IsNaN(y) {
+		y -= (math.Pow(y, n) - x) / (n * math.Pow(y, n-1)) // Newton step corrects rounding of 1/n.
+	}
+	return y
+}
+
+func roundFunc(args []Value) (Value, error) {
+	scale := 1.0
+	if len(args) == 2 {
+		digits, err := args[1].Float()
+		if err != nil {
+			return Value{}, err
+		}
+		scale = math.Pow(10, digits)
+	}
+	round := func(x float64) float64 { return math.Round(x*scale) / scale }
+	return mapValue(args[0], func(z complex128) complex128 { return complex(round(real(z)), round(imag(z))) }), nil
+}
+
+// reduceFunc returns a function reducing vectors with f, or each column of a matrix
+// as MATLAB does by default. A dimension argument selects columns (1) or rows (2).
+func reduceFunc(f func([]complex128) (complex128, error)) Func {
+	return func(args []Value) (Value, error) {
+		v := args[0]
+		if len(args) == 3 {
+			return Value{}, errors.New("third argument not supported")
+		}
+		dim := 1
+		if v.Rows == 1 {
+			dim = 2
+		}
+		if len(args) == 2 {
+			d, err := args[1].Float()
+			if err != nil || (d != 1 && d != 2) {
+				return Value{}, errors.New("dimension must be 1 or 2")
+			}
+			dim = int(d)
+		}
+		if dim == 2 {
+			v = transpose(v)
+		}
+		r := newMatrix(1, v.Cols)
+		for j := range v.Cols {
+			var err error
+			r.Data[j], err = f(v.Data[j*v.Rows : (j+1)*v.Rows])
+			if err != nil {
+				return Value{}, err
+			}
+		}
+		if dim == 2 {
+			r = transpose(r)
+		}
+		return r, nil
+	}
+}
+
+// minMaxFunc returns min or max: the element-wise form of two arguments with
+// scalar broadcasting, or the reduction of one argument, along dimension dim
+// with the three argument form f(A,[],dim).
+func minMaxFunc(better func(a, b float64) bool) Func {
+	reduce := reduceFunc(minMax(better))
+	pick := elementWise2(func(a, b complex128) complex128 {
+		if better(real(b), real(a)) || math.IsNaN(real(a)) {
+			return b // NaN is ignored as in MATLAB.
+		}
+		return a
+	})
+	return func(args []Value) (Value, error) {
+		switch len(args) {
+		case 2:
+			if !args[0].IsReal() || !args[1].IsReal() {
+				return Value{}, errComplexArg
+			}
+			return pick(args)
+		case 3:
+			if len(args[1].Data) != 0 {
+				return Value{}, errors.New("second argument must be [] when a dimension is given")
+			}
+			return reduce([]Value{args[0], args[2]})
+		}
+		return reduce(args)
+	}
+}
+
+func minMax(better func(a, b float64) bool) func([]complex128) (complex128, error) {
+	return func(v []complex128) (complex128, error) {
+		if len(v) == 0 {
+			return 0, errors.New("empty argument")
+		}
+		best := v[0]
+		for _, z := range v {
+			if imag(z) != 0 {
+				return 0, errComplexArg
+			} else if better(real(z), real(best)) || math.IsNaN(real(best)) {
+				best = z
+			}
+		}
+		return best, nil
+	}
+}
+
+func sum(v []complex128) (s complex128) {
+	for _, z := range v {
+		s += z
+	}
+	return s
+}
+
+func prod(v []complex128) (complex128, error) {
+	p := complex128(1)
+	for _, z := range v {
+		p *= z
+	}
+	return p, nil
+}
+
+func isVector(v Value) bool { return v.Rows == 1 || v.Cols == 1 }
+
+func normFunc(args []Value) (Value, error) {
+	v := args[0]
+	if !isVector(v) {
+		return Value{}, errors.New("matrix norm not supported")
+	}
+	p := 2.0
+	if len(args) == 2 {
+		var err error
+		if p, err = args[1].Float(); err != nil {
+			return Value{}, err
+		}
+	}
+	var n float64
+	switch {
+	case math.IsInf(p, 1):
+		for _, z := range v.Data {
+			n = max(n, cmplx.Abs(z))
+		}
+	case p == 1:
+		for _, z := range v.Data {
+			n += cmplx.Abs(z)
+		}
+	default:
+		for _, z := range v.Data {
+			n += math.Pow(cmplx.Abs(z), p)
+		}
+		n = math.Pow(n, 1/p)
+	}
+	return Scalar(complex(n, 0)), nil
+}
+
+func dotFunc(args []Value) (Value, error) {
+	a, b := args[0], args[1]
+	if len(args) == 3 || !isVector(a) || len(a.Data) != len(b.Data) {
+		return Value{}, errors.New("arguments must be vectors of the same length")
+	}
+	var d complex128
+	for i := range a.Data {
+		d += cmplx.Conj(a.Data[i]) * b.Data[i]
+	}
+	return Scalar(d), nil
+}
+
+func crossFunc(args []Value) (Value, error) {
+	a, b := args[0], args[1]
+	if len(args) == 3 || len(a.Data) != 3 || len(b.Data) != 3 || !isVector(a) || !isVector(b) {
+		return Value{}, errors.New("arguments must be 3 element vectors")
+	}
+	x, y := a.Data, b.Data
+	r := Value{Rows: a.Rows, Cols: a.Cols, Data: []complex128{
+		x[1]*y[2] - x[2]*y[1],
+		x[2]*y[0] - x[0]*y[2],
+		x[0]*y[1] - x[1]*y[0],
+	}}
+	return r, nil
+}
+
+func transpose(v Value) Value {
+	r := newMatrix(v.Cols, v.Rows)
+	for i := range v.Rows {
+		for j := range v.Cols {
+			r.Data[i*r.Rows+j] = v.At(i, j)
+		}
+	}
+	return r
+}
+
+func transposeFunc(args []Value) (Value, error) { return transpose(args[0]), nil }
+
+func traceFunc(args []Value) (Value, error) {
+	v := args[0]
+	if v.Rows != v.Cols {
+		return Value{}, errors.New("matrix must be square")
+	}
+	var t complex128
+	for i := range v.Rows {
+		t += v.At(i, i)
+	}
+	return Scalar(t), nil
+}
+
+// lu computes the LU decomposition of square v in place with partial pivoting,
+// returning the row permutation and its sign, or ok false if v is singular.
+func lu(v Value) (a Value, perm []int, sign float64, ok bool) {
+	n := v.Rows
+	a = Value{Rows: n, Cols: n, Data: append([]complex128(nil), v.Data...)}
+	perm = make([]int, n)
+	for i := range perm {
+		perm[i] = i
+	}
+	sign = 1
+	at := func(i, j int) *complex128 { return &a.Data[j*n+i] }
+	for k := range n {
+		p := k
+		for i := k + 1; i < n; i++ {
+			if cmplx.Abs(*at(i, k)) > cmplx.Abs(*at(p, k)) {
+				p = i
+			}
+		}
+		if *at(p, k) == 0 {
+			return a, perm, 0, false
+		}
+		if p != k {
+			for j := range n {
+				*at(p, j), *at(k, j) = *at(k, j), *at(p, j)
+			}
+			perm[p], perm[k] = perm[k], perm[p]
+			sign = -sign
+		}
+		for i := k + 1; i < n; i++ {
+			*at(i, k) /= *at(k, k)
+			for j := k + 1; j < n; j++ {
+				*at(i, j) -= *at(i, k) * *at(k, j)
+			}
+		}
+	}
+	return a, perm, sign, true
+}
+
+func detFunc(args []Value) (Value, error) {
+	v := args[0]
+	if v.Rows != v.Cols {
+		return Value{}, errors.New("matrix must be square")
+	}
+	a, _, sign, ok := lu(v)
+	if !ok {
+		return Scalar(0), nil
+	}
+	d := complex(sign, 0)
+	for i := range a.Rows {
+		d *= a.At(i, i)
+	}
+	return Scalar(d), nil
+}
+
+func invFunc(args []Value) (Value, error) {
+	v := args[0]
+	if v.Rows != v.Cols {
+		return Value{}, errors.New("matrix must be square")
+	}
+	n := v.Rows
+	a, perm, _, ok := lu(v)
+	if !ok {
+		return Value{}, errors.New("matrix is singular")
+	}
+	r := newMatrix(n, n)
+	col := make([]complex128, n)
+	for j := range n {
+		// Solve L*U*x = P*e_j.
+		for i := range n {
+			col[i] = 0
+			if perm[i] == j {
+				col[i] = 1
+			}
+		}
+		for i := range n {
+			for k := range i {
+				col[i] -= a.At(i, k) * col[k]
+			}
+		}
+		for i := n - 1; i >= 0; i-- {
+			for k := i + 1; k < n; k++ {
+				col[i] -= a.At(i, k) * col[k]
+			}
+			col[i] /= a.At(i, i)
+		}
+		copy(r.Data[j*n:], col)
+	}
+	return r, nil
+}
+
+func sizeFunc(args []Value) (Value, error) {
+	v := args[0]
+	if len(args) == 2 {
+		d, err := args[1].Float()
+		switch {
+		case err != nil || d < 1 || d != math.Trunc(d):
+			return Value{}, errors.New("dimension must be a positive integer")
+		case d == 1:
+			return Scalar(complex(float64(v.Rows), 0)), nil
+		case d == 2:
+			return Scalar(complex(float64(v.Cols), 0)), nil
+		}
+		return Scalar(1), nil
+	}
+	return Value{Rows: 1, Cols: 2, Data: []complex128{complex(float64(v.Rows), 0), complex(float64(v.Cols), 0)}}, nil
+}
+
+// filled returns a function creating a matrix with elements f(i, j) of size given by
+// its arguments as zeros does: none for 1x1, n for nxn or rows and columns.
+func filled(f func(i, j int) complex128) Func {
+	return func(args []Value) (Value, error) {
+		var dims [2]int
+		switch len(args) {
+		case 0:
+			dims = [2]int{1, 1}
+		case 1, 2:
+			for i := range dims {
+				d, err := args[min(i, len(args)-1)].Float()
+				if err != nil || d < 0 || d != math.Trunc(d) {
+					return Value{}, errors.New("size must be a non-negative integer")
+				}
+				dims[i] = int(d)
+			}
+		default:
+			return Value{}, errors.New("only 2 dimensions supported")
+		}
+		r := newMatrix(dims[0], dims[1])
+		for j := range r.Cols {
+			for i := range r.Rows {
+				r.Data[j*r.Rows+i] = f(i, j)
+			}
+		}
+		return r, nil
+	}
+}
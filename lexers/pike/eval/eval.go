@@ -0,0 +1,394 @@
+// Package eval evaluates MATLAB expression trees parsed by [parse.Parse]
+// with variables bound to vectors of numbers, as in the state-space
+// models of the example where X and U are the state and input vectors:
+//
+//	x, err := parse.Parse(lexer.Items())
+//	...
+//	v, err := eval.Eval(x, &eval.Env{Vars: map[string][]float64{"X": X, "U": U}})
+//	...
+//	f, err := v.Floats()
+//
+// Values are complex matrices so that imaginary literals such as 2i and
+// functions of negative arguments such as sqrt(-1) evaluate as in MATLAB.
+// Operations between a matrix and a scalar are element-wise.
+package eval
+
+import (
+	"fmt"
+	"math"
+	"math/cmplx"
+	"strconv"
+	"strings"
+
+	"github.com/soypat/lexer/lexers/pike"
+	"github.com/soypat/lexer/lexers/pike/parse"
+)
+
+// Env binds the names of an expression.
+type Env struct {
+	// Vars binds variables to column vectors indexed from 1 as in MATLAB, so X(4) is Vars["X"][3].
+	Vars map[string][]float64
+	// Funcs binds functions, taking precedence over the built-in functions of pike.
+	Funcs map[string]Func
+}
+
+// Func is the implementation of a function.
+type Func func(args []Value) (Value, error)
+
+// ElementWise returns a function of one argument applying f to each of its elements.
+func ElementWise(f func(complex128) complex128) Func {
+	return func(args []Value) (Value, error) {
+		if len(args) != 1 {
+			return Value{}, fmt.Errorf("want 1 argument, got %d", len(args))
+		}
+		return mapValue(args[0], f), nil
+	}
+}
+
+// Error is an evaluation error positioned in the input of the expression.
+type Error struct {
+	Pos, End int // Byte offsets of the expression that failed to evaluate.
+	Msg      string
+}
+
+// Error returns the error formatted as "offset: message".
+func (e *Error) Error() string {
+	return strconv.Itoa(e.Pos) + ": " + e.Msg
+}
+
+// Eval evaluates x with the names bound by env.
+func Eval(x parse.Expr, env *Env) (Value, error) {
+	if env == nil {
+		env = &Env{}
+	}
+	return env.eval(x)
+}
+
+func errorf(x parse.Expr, format string, args ...any) error {
+	return &Error{Pos: x.Pos(), End: x.End(), Msg: fmt.Sprintf(format, args...)}
+}
+
+func (env *Env) eval(x parse.Expr) (Value, error) {
+	switch x := x.(type) {
+	case *parse.Number:
+		z, err := ParseNumber(x.Value)
+		if err != nil {
+			return Value{}, errorf(x, "%v", err)
+		}
+		return Scalar(z), nil
+	case *parse.Ident:
+		if v, ok := env.Vars[x.Name]; ok && x.Kind != parse.IdentFunc {
+			return Column(v), nil
+		}
+		return env.call(x, x, nil)
+	case *parse.Paren:
+		return env.eval(x.X)
+	case *parse.Unary:
+		v, err := env.eval(x.X)
+		if err != nil || x.Op == '+' {
+			return v, err
+		}
+		return mapValue(v, neg), nil
+	case *parse.Binary:
+		a, err := env.eval(x.X)
+		if err != nil {
+			return Value{}, err
+		}
+		b, err := env.eval(x.Y)
+		if err != nil {
+			return Value{}, err
+		}
+		v, err := binary(x.Op, a, b)
+		if err != nil {
+			return Value{}, errorf(x, "%v", err)
+		}
+		return v, nil
+	case *parse.Range:
+		return env.evalRange(x)
+	case *parse.Index:
+		return env.index(x, x.X.Name, x.Args)
+	case *parse.Call:
+		if _, ok := env.Vars[x.Func.Name]; ok && x.Func.Kind != parse.IdentFunc {
+			return env.index(x, x.Func.Name, x.Args) // Undefined identifier at lexing.
+		}
+		return env.call(x, x.Func, x.Args)
+	case *parse.Matrix:
+		return env.evalMatrix(x)
+	case *parse.Colon:
+		return Value{}, errorf(x, "':' is only valid as an index")
+	}
+	return Value{}, errorf(x, "unsupported expression %T", x)
+}
+
+// ParseNumber parses a numeric literal as lexed by pike, such as 2.5e-3 or 3i.
+func ParseNumber(lit string) (complex128, error) {
+	s, imaginary := strings.CutSuffix(lit, "i")
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %q", lit)
+	}
+	if imaginary {
+		return complex(0, f), nil
+	}
+	return complex(f, 0), nil
+}
+
+func (env *Env) evalArgs(args []parse.Expr) ([]Value, error) {
+	vals := make([]Value, len(args))
+	for i, arg := range args {
+		v, err := env.eval(arg)
+		if err != nil {
+			return nil, err
+		}
+		vals[i] = v
+	}
+	return vals, nil
+}
+
+func (env *Env) call(x parse.Expr, fn *parse.Ident, args []parse.Expr) (Value, error) {
+	f, ok := env.Funcs[fn.Name]
+	if !ok {
+		b, ok := builtins[fn.Name]
+		if !ok {
+			return Value{}, errorf(fn, "undefined function or variable %s", fn.Name)
+		}
+		if info, _ := pike.LookupFunc(fn.Name); !info.AcceptsArgs(len(args)) {
+			return Value{}, errorf(x, "%s called with %d arguments", fn.Name, len(args))
+		}
+		f = b
+	}
+	vals, err := env.evalArgs(args)
+	if err != nil {
+		return Value{}, err
+	}
+	v, err := f(vals)
+	if err != nil {
+		return Value{}, errorf(x, "%s: %v", fn.Name, err)
+	}
+	return v, nil
+}
+
+// index returns elements of the vector variable name selected by 1-based
+// linear index args[0], or by row and column args[0] and args[1].
+func (env *Env) index(x parse.Expr, name string, args []parse.Expr) (Value, error) {
+	vec, ok := env.Vars[name]
+	if !ok {
+		return Value{}, errorf(x, "undefined variable %s", name)
+	} else if len(args) == 0 || len(args) > 2 {
+		return Value{}, errorf(x, "index of %s must have 1 or 2 subscripts, got %d", name, len(args))
+	}
+	dims := [2]int{len(vec), 1}
+	if len(args) == 1 {
+		dims[0] = len(vec) // Linear indexing.
+	}
+	var subs [2][]int
+	for i, arg := range args {
+		if _, ok := arg.(*parse.Colon); ok {
+			for k := range dims[i] {
+				subs[i] = append(subs[i], k)
+			}
+			continue
+		}
+		v, err := env.eval(arg)
+		if err != nil {
+			return Value{}, err
+		}
+		for _, z := range v.Data {
+			k := real(z)
+			if imag(z) != 0 || k != math.Trunc(k) || k < 1 {
+				return Value{}, errorf(arg, "index must be a positive integer, got %s", formatElem(z))
+			} else if int(k) > dims[i] {
+				return Value{}, errorf(arg, "index %d exceeds %s dimension of %d", int(k), name, dims[i])
+			}
+			subs[i] = append(subs[i], int(k)-1)
+		}
+	}
+	if len(args) == 1 {
+		r := newMatrix(len(subs[0]), 1)
+		for i, k := range subs[0] {
+			r.Data[i] = complex(vec[k], 0)
+		}
+		return r, nil
+	}
+	r := newMatrix(len(subs[0]), len(subs[1]))
+	for i, k := range subs[0] {
+		r.Data[i] = complex(vec[k], 0) // Only column 1 exists.
+	}
+	for j := 1; j < r.Cols; j++ {
+		copy(r.Data[j*r.Rows:], r.Data[:r.Rows])
+	}
+	return r, nil
+}
+
+// maxRangeLen is the maximum number of elements of a range, which bounds its allocation.
+const maxRangeLen = 1 << 24
+
+func (env *Env) evalRange(x *parse.Range) (Value, error) {
+	var bounds [3]float64
+	bounds[1] = 1 // Default step.
+	for i, e := range []parse.Expr{x.Start, x.Step, x.Stop} {
+		if e == nil {
+			continue
+		}
+		v, err := env.eval(e)
+		if err != nil {
+			return Value{}, err
+		}
+		bounds[i], err = v.Float()
+		if err != nil {
+			return Value{}, errorf(e, "range bound: %v", err)
+		}
+	}
+	start, step, stop := bounds[0], bounds[1], bounds[2]
+	if step == 0 {
+		return Value{}, errorf(x, "range step is zero")
+	}
+	n := math.Floor((stop-start)/step+1e-10) + 1
+	if math.IsNaN(n) || n > maxRangeLen {
+		return Value{}, errorf(x, "range of %g elements exceeds maximum of %d", n, maxRangeLen)
+	}
+	r := newMatrix(1, int(max(n, 0)))
+	for i := range r.Data {
+		r.Data[i] = complex(start+float64(i)*step, 0)
+	}
+	return r, nil
+}
+
+// evalMatrix concatenates the elements of each row horizontally and the rows vertically.
+func (env *Env) evalMatrix(x *parse.Matrix) (Value, error) {
+	var (
+		rows  []Value
+		first []parse.Expr // First element of each row for error positions.
+	)
+	for _, row := range x.Rows {
+		elems, err := env.evalArgs(row)
+		if err != nil {
+			return Value{}, err
+		} else if len(elems) == 0 {
+			continue // Empty row of a tree not built by the parser.
+		}
+		r := Value{Rows: elems[0].Rows}
+		for i, e := range elems {
+			if e.Rows != r.Rows {
+				return Value{}, errorf(row[i], "horizontal dimensions mismatch: %d rows, want %d", e.Rows, r.Rows)
+			}
+			r.Cols += e.Cols
+			r.Data = append(r.Data, e.Data...) // Column-major concatenates columns.
+		}
+		rows = append(rows, r)
+		first = append(first, row[0])
+	}
+	if len(rows) == 0 {
+		return Value{}, nil
+	}
+	m := newMatrix(0, rows[0].Cols)
+	for i, r := range rows {
+		if r.Cols != m.Cols {
+			return Value{}, errorf(first[i], "vertical dimensions mismatch: %d columns, want %d", r.Cols, m.Cols)
+		}
+		m.Rows += r.Rows
+	}
+	m.Data = make([]complex128, m.Rows*m.Cols)
+	off := 0
+	for _, r := range rows {
+		for j := range r.Cols {
+			for i := range r.Rows {
+				m.Data[j*m.Rows+off+i] = r.At(i, j)
+			}
+		}
+		off += r.Rows
+	}
+	return m, nil
+}
+
+func binary(op byte, a, b Value) (Value, error) {
+	switch {
+	case a.IsScalar() && b.IsScalar():
+		return Scalar(scalarOp(op, a.Data[0], b.Data[0])), nil
+	case b.IsScalar() && op != '^':
+		return mapValue(a, func(z complex128) complex128 { return scalarOp(op, z, b.Data[0]) }), nil
+	case a.IsScalar() && (op == '+' || op == '-' || op == '*'):
+		return mapValue(b, func(z complex128) complex128 { return scalarOp(op, a.Data[0], z) }), nil
+	case (op == '+' || op == '-') && a.Rows == b.Rows && a.Cols == b.Cols:
+		r := newMatrix(a.Rows, a.Cols)
+		for i := range r.Data {
+			r.Data[i] = scalarOp(op, a.Data[i], b.Data[i])
+		}
+		return r, nil
+	case op == '*' && a.Cols == b.Rows:
+		r := newMatrix(a.Rows, b.Cols)
+		for i := range r.Rows {
+			for j := range r.Cols {
+				var sum complex128
+				for k := range a.Cols {
+					sum += a.At(i, k) * b.At(k, j)
+				}
+				r.Data[j*r.Rows+i] = sum
+			}
+		}
+		return r, nil
+	}
+	return Value{}, fmt.Errorf("operator %c not supported for %dx%d and %dx%d operands", op, a.Rows, a.Cols, b.Rows, b.Cols)
+}
+
+// neg negates z. Real numbers stay real: their imaginary part is not negated to -0,
+// which would place them on the other side of branch cuts as in sqrt(-4).
+func neg(z complex128) complex128 {
+	if imag(z) == 0 {
+		return complex(-real(z), 0)
+	}
+	return -z
+}
+
+// powInt computes z^n by repeated squaring.
+func powInt(z complex128, n int) complex128 {
+	if n < 0 {
+		return 1 / powInt(z, -n)
+	}
+	r := complex128(1)
+	for ; n > 0; n >>= 1 {
+		if n&1 == 1 {
+			r *= z
+		}
+		z *= z
+	}
+	return r
+}
+
+// scalarOp computes a op b, in real arithmetic if both are real to match
+// MATLAB's handling of infinities and negative zero.
+func scalarOp(op byte, a, b complex128) complex128 {
+	if imag(a) == 0 && imag(b) == 0 {
+		x, y := real(a), real(b)
+		switch op {
+		case '+':
+			return complex(x+y, 0)
+		case '-':
+			return complex(x-y, 0)
+		case '*':
+			return complex(x*y, 0)
+		case '/':
+			return complex(x/y, 0)
+		case '^':
+			if x >= 0 || y == math.Trunc(y) {
+				return complex(math.Pow(x, y), 0)
+			}
+		}
+	}
+	if op == '^' && imag(b) == 0 && real(b) == math.Trunc(real(b)) && math.Abs(real(b)) <= 64 {
+		return powInt(a, int(real(b))) // Exact for small integer powers unlike cmplx.Pow.
+	}
+	switch op {
+	case '+':
+		return a + b
+	case '-':
+		return a - b
+	case '*':
+		return a * b
+	case '/':
+		return a / b
+	case '^':
+		return cmplx.Pow(a, b)
+	}
+	return cmplx.NaN()
+}
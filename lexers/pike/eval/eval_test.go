@@ -0,0 +1,214 @@
+package eval
+
+import (
+	"errors"
+	"math"
+	"math/cmplx"
+	"testing"
+
+	"github.com/soypat/lexer/lexers/pike"
+	"github.com/soypat/lexer/lexers/pike/parse"
+)
+
+var (
+	testX = []float64{1, 2, 3, 0.1, -0.2, 0.3, 7, 8, 9, 10, 11, 12}
+	testU = []float64{1.5, 0.25, -0.5, 2}
+)
+
+func evalString(t *testing.T, input string, env *Env) (Value, error) {
+	t.Helper()
+	l, err := pike.NewLexer("test.m", input, pike.Options{Variables: []string{"X", "U"}, Builtins: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	x, err := parse.Parse(l.Items())
+	if err != nil {
+		t.Fatalf("%q: %v", input, err)
+	}
+	return Eval(x, env)
+}
+
+func testEnv() *Env {
+	return &Env{Vars: map[string][]float64{"X": testX, "U": testU}}
+}
+
+func TestEvalDynamics(t *testing.T) {
+	// Second row of the example's f2 state derivative.
+	const f2 = `(U(1)*sin(U(2))*(cos(X(4))*sin(X(6))-cos(X(6))*sin(X(4))*sin(X(5))))/4+(U(1)*cos(U(3)+U(2))*(sin(X(4))*sin(X(6))+cos(X(4))*cos(X(6))*sin(X(5))))/4+(U(1)*cos(X(5))*cos(X(6))*sin(U(3)))/4`
+	X, U := testX, testU
+	want := (U[0]*math.Sin(U[1])*(math.Cos(X[3])*math.Sin(X[5])-math.Cos(X[5])*math.Sin(X[3])*math.Sin(X[4])))/4 +
+		(U[0]*math.Cos(U[2]+U[1])*(math.Sin(X[3])*math.Sin(X[5])+math.Cos(X[3])*math.Cos(X[5])*math.Sin(X[4])))/4 +
+		(U[0]*math.Cos(X[4])*math.Cos(X[5])*math.Sin(U[2]))/4
+	v, err := evalString(t, f2, testEnv())
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := v.Float()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(got-want) > 1e-15 {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	v, err = evalString(t, "[X(7);X(8);"+f2+";-979/100]", testEnv())
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs, err := v.Floats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fs) != 4 || v.Cols != 1 || fs[0] != 7 || fs[1] != 8 || fs[2] != got || fs[3] != -9.79 {
+		t.Errorf("got %v (%dx%d)", fs, v.Rows, v.Cols)
+	}
+}
+
+func TestEval(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"2^3^2", "64"},
+		{"2^(3^2)", "512"},
+		{"-2^2", "-4"},
+		{"2^-1", "0.5"},
+		{"7-2-1", "4"},
+		{"sqrt(-4)", "(0+2i)"},
+		{"(2i)^2", "-4"},
+		{"abs(3+4i)", "5"},
+		{"real(exp(pi()*1i))", "-1"},
+		{"real(exp(pi*1i))", "-1"},
+		{"eps", "2.220446049250313e-16"},
+		{"1:3", "[1,2,3]"},
+		{"10:-4:1", "[10,6,2]"},
+		{"[1,2;3,4]*[1;1]", "[3;7]"},
+		{"[1,2;3,4]*2-1", "[1,3;5,7]"},
+		{"[[1;2],[3;4]]", "[1,3;2,4]"},
+		{"X(1:3)", "[1;2;3]"},
+		{"X(2,1)", "2"},
+		{"U(:)*2", "[3;0.5;-1;4]"},
+		{"sum(X(1:3))", "6"},
+		{"max([3,9,2])", "9"},
+		{"min([1,2;0,5])", "[0,2]"},
+		{"max(1,2)", "2"},
+		{"max(X(1),0)", "1"},
+		{"min(X(1:3),2)", "[1;2;2]"},
+		{"max([1,5],[4,2])", "[4,5]"},
+		{"max(0/0,1)", "1"},
+		{"max([1,2;3,0],[],2)", "[2;3]"},
+		{"min([1,2;3,0],[],1)", "[1,0]"},
+		{"sum([1,2;3,4],2)", "[3;7]"},
+		{"mod(-7,3)", "2"},
+		{"rem(-7,3)", "-1"},
+		{"atan2(1,1)*4", "3.141592653589793"},
+		{"norm([3,4])", "5"},
+		{"dot([1,2,3],[4,5,6])", "32"},
+		{"cross([1,0,0],[0,1,0])", "[0,0,1]"},
+		{"det([1,2;3,4])", "-2"},
+		{"inv([2,0;0,4])", "[0.5,0;0,0.25]"},
+		{"trace(eye(3))", "3"},
+		{"size(zeros(2,3))", "[2,3]"},
+		{"numel(ones(2))", "4"},
+		{"transpose([1,2])", "[1;2]"},
+		{"nthroot(-27,3)", "-3"},
+		{"round(2.567,2)", "2.57"},
+		{"floor(-2.5)", "-3"},
+		{"sign(-3)", "-1"},
+	}
+	for _, test := range tests {
+		v, err := evalString(t, test.input, testEnv())
+		if err != nil {
+			t.Errorf("%q: %v", test.input, err)
+			continue
+		}
+		if got := v.String(); got != test.want {
+			t.Errorf("%q: got %s, want %s", test.input, got, test.want)
+		}
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		pos   int
+	}{
+		{"X(13)", 2},
+		{"X(0.5)", 2},
+		{"U(1,2)", 4},
+		{"atan2(1)", 0},
+		{"[1,2]+[1,2,3]", 0},
+		{"[1,2;3]", 5},
+		{"atan2(1i,1)", 0},
+		{"1:0:3", 0},
+		{"max([1,2],[1,2,3])", 0},
+		{"max([1,2],1,2)", 0},
+		{"max(1i,2)", 0},
+		{"1:1e12", 0},
+		{"1:0/0", 0},
+	}
+	for _, test := range tests {
+		_, err := evalString(t, test.input, testEnv())
+		var eerr *Error
+		if !errors.As(err, &eerr) {
+			t.Errorf("%q: expected *Error, got %v", test.input, err)
+		} else if eerr.Pos != test.pos {
+			t.Errorf("%q: got error at %d, want %d: %v", test.input, eerr.Pos, test.pos, err)
+		}
+	}
+}
+
+func TestEvalMatrixEmptyRow(t *testing.T) {
+	one, two := &parse.Number{Value: "1"}, &parse.Number{Value: "2"}
+	x := &parse.Matrix{Rows: [][]parse.Expr{{one, two}, {}, {two, one}}}
+	v, err := Eval(x, testEnv())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := v.String(); got != "[1,2;2,1]" {
+		t.Errorf("got %s, want [1,2;2,1]", got)
+	}
+}
+
+func TestEvalFuncs(t *testing.T) {
+	env := testEnv()
+	env.Funcs = map[string]Func{
+		"sin":   ElementWise(func(z complex128) complex128 { return 42 }), // Override built-in.
+		"twice": ElementWise(func(z complex128) complex128 { return 2 * z }),
+	}
+	l, err := pike.NewLexer("test.m", "sin(1)+twice(X(2))+Y", pike.Options{Variables: []string{"X"}, Functions: []string{"sin", "twice"}, AllowUndefined: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	x, err := parse.Parse(l.Items())
+	if err != nil {
+		t.Fatal(err)
+	}
+	env.Vars["Y"] = []float64{0.5}
+	v, err := Eval(x, env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := v.Float(); got != 46.5 {
+		t.Errorf("got %v, want 46.5", got)
+	}
+}
+
+func TestBuiltinsImplemented(t *testing.T) {
+	for _, f := range pike.Builtins() {
+		if _, ok := builtins[f.Name]; !ok {
+			t.Errorf("built-in %s not implemented", f.Name)
+		}
+	}
+	// Complex paths agree with real paths inside the real domain.
+	for _, name := range []string{"sin", "cos", "tan", "asin", "acos", "atan", "sinh", "cosh", "tanh", "asinh", "atanh", "exp", "log", "sqrt", "log10"} {
+		v, err := builtins[name]([]Value{Scalar(0.5 + 1e-300i)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, _ := builtins[name]([]Value{Scalar(0.5)})
+		if cmplx.Abs(v.Data[0]-r.Data[0]) > 1e-12 {
+			t.Errorf("%s: complex %v and real %v differ", name, v.Data[0], r.Data[0])
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package eval
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Value is a MATLAB value: a matrix of complex numbers. Scalars are 1x1 matrices.
+type Value struct {
+	Rows, Cols int
+	Data       []complex128 // Elements in column-major order as in MATLAB.
+}
+
+// Scalar returns the 1x1 value z.
+func Scalar(z complex128) Value {
+	return Value{Rows: 1, Cols: 1, Data: []complex128{z}}
+}
+
+// Column returns the column vector of elements v.
+func Column(v []float64) Value {
+	data := make([]complex128, len(v))
+	for i, f := range v {
+		data[i] = complex(f, 0)
+	}
+	return Value{Rows: len(v), Cols: 1, Data: data}
+}
+
+// IsScalar reports whether v is 1x1.
+func (v Value) IsScalar() bool { return v.Rows == 1 && v.Cols == 1 }
+
+// IsReal reports whether all elements of v have zero imaginary part.
+func (v Value) IsReal() bool {
+	for _, z := range v.Data {
+		if imag(z) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// At returns the element at the 0-based row i and column j.
+func (v Value) At(i, j int) complex128 { return v.Data[j*v.Rows+i] }
+
+// Float returns the value of a real scalar.
+func (v Value) Float() (float64, error) {
+	if !v.IsScalar() {
+		return 0, fmt.Errorf("expected scalar, got %dx%d matrix", v.Rows, v.Cols)
+	} else if imag(v.Data[0]) != 0 {
+		return 0, fmt.Errorf("expected real scalar, got %v", v.Data[0])
+	}
+	return real(v.Data[0]), nil
+}
+
+// Floats returns the elements of a real value in column-major order.
+func (v Value) Floats() ([]float64, error) {
+	return v.AppendFloats(nil)
+}
+
+// AppendFloats appends the elements of a real value in column-major order to dst.
+func (v Value) AppendFloats(dst []float64) ([]float64, error) {
+	if !v.IsReal() {
+		return dst, errors.New("expected real value, got complex")
+	}
+	for _, z := range v.Data {
+		dst = append(dst, real(z))
+	}
+	return dst, nil
+}
+
+// String returns v formatted as a MATLAB matrix literal, or a number if scalar.
+func (v Value) String() string {
+	if v.IsScalar() {
+		return formatElem(v.Data[0])
+	}
+	b := []byte{'['}
+	for i := range v.Rows {
+		if i > 0 {
+			b = append(b, ';')
+		}
+		for j := range v.Cols {
+			if j > 0 {
+				b = append(b, ',')
+			}
+			b = append(b, formatElem(v.At(i, j))...)
+		}
+	}
+	return string(append(b, ']'))
+}
+
+func formatElem(z complex128) string {
+	if imag(z) == 0 {
+		return fmt.Sprint(real(z))
+	}
+	return fmt.Sprint(z)
+}
+
+func newMatrix(rows, cols int) Value {
+	return Value{Rows: rows, Cols: cols, Data: make([]complex128, rows*cols)}
+}
+
+// mapValue applies f to every element of v.
+func mapValue(v Value, f func(complex128) complex128) Value {
+	r := newMatrix(v.Rows, v.Cols)
+	for i, z := range v.Data {
+		r.Data[i] = f(z)
+	}
+	return r
+}
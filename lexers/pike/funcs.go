@@ -0,0 +1,66 @@
+package pike
+
+// Variadic is the MaxArgs of functions taking any number of arguments.
+const Variadic = -1
+
+// Func describes a built-in MATLAB/Octave function.
+type Func struct {
+	Name    string
+	MinArgs int
+	MaxArgs int // Variadic if there is no maximum.
+}
+
+// AcceptsArgs reports whether the function may be called with n arguments.
+func (f Func) AcceptsArgs(n int) bool {
+	return n >= f.MinArgs && (f.MaxArgs == Variadic || n <= f.MaxArgs)
+}
+
+// builtinFuncs is the catalogue of common MATLAB/Octave math functions.
+// Registered as functions by lexers created with Options.Builtins set.
+var builtinFuncs = []Func{
+	// Trigonometric and hyperbolic.
+	{"sin", 1, 1}, {"cos", 1, 1}, {"tan", 1, 1},
+	{"asin", 1, 1}, {"acos", 1, 1}, {"atan", 1, 1}, {"atan2", 2, 2},
+	{"sec", 1, 1}, {"csc", 1, 1}, {"cot", 1, 1},
+	{"sinh", 1, 1}, {"cosh", 1, 1}, {"tanh", 1, 1},
+	{"asinh", 1, 1}, {"acosh", 1, 1}, {"atanh", 1, 1},
+	{"hypot", 2, 2},
+	// Exponential, logarithmic and roots.
+	{"exp", 1, 1}, {"expm1", 1, 1}, {"log", 1, 1}, {"log1p", 1, 1}, {"log2", 1, 1}, {"log10", 1, 1},
+	{"sqrt", 1, 1}, {"nthroot", 2, 2}, {"power", 2, 2},
+	// Rounding, sign and remainders.
+	{"abs", 1, 1}, {"sign", 1, 1}, {"floor", 1, 1}, {"ceil", 1, 1}, {"fix", 1, 1}, {"round", 1, 2},
+	{"mod", 2, 2}, {"rem", 2, 2},
+	// Complex numbers.
+	{"real", 1, 1}, {"imag", 1, 1}, {"conj", 1, 1}, {"angle", 1, 1},
+	// Special functions.
+	{"gamma", 1, 1}, {"erf", 1, 1}, {"erfc", 1, 1},
+	// Reductions.
+	{"min", 1, 3}, {"max", 1, 3}, {"sum", 1, 3}, {"prod", 1, 3}, {"mean", 1, 3},
+	{"norm", 1, 2}, {"dot", 2, 3}, {"cross", 2, 3},
+	// Matrices.
+	{"transpose", 1, 1}, {"inv", 1, 1}, {"det", 1, 1}, {"trace", 1, 1},
+	{"size", 1, 2}, {"numel", 1, 1}, {"length", 1, 1},
+	{"zeros", 0, Variadic}, {"ones", 0, Variadic}, {"eye", 0, Variadic},
+	// Constants, callable with dimensions.
+	{"pi", 0, Variadic}, {"eps", 0, Variadic},
+}
+
+var funcNames = func() map[string]Func {
+	m := make(map[string]Func, len(builtinFuncs))
+	for _, f := range builtinFuncs {
+		m[f.Name] = f
+	}
+	return m
+}()
+
+// Builtins returns the catalogue of built-in functions.
+func Builtins() []Func {
+	return append([]Func(nil), builtinFuncs...)
+}
+
+// LookupFunc returns the built-in function named name.
+func LookupFunc(name string) (f Func, ok bool) {
+	f, ok = funcNames[name]
+	return f, ok
+}
@@ -1,9 +1,16 @@
 package pike
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"iter"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"unicode/utf8"
+
+	"github.com/soypat/lexer/diag"
 )
 
 type ItemType int
@@ -40,8 +47,12 @@ const (
 )
 
 type Item struct {
-	typ ItemType // such as ItemNumber
-	val string
+	typ       ItemType // such as ItemNumber
+	val       string
+	start     int    // byte offset of start of item in input
+	end       int    // byte offset after end of item in input
+	line, col int    // 1-based line and column of start, column counted in runes
+	msg       string // error message of ItemError without the source snippet
 }
 
 // Identifier types. Variables and functions (keywords?)
@@ -55,46 +66,131 @@ const (
 )
 
 type identifier struct {
-	typ idType // such as idFunc
-	val string
+	typ     idType // such as idFunc
+	val     string
+	nullary bool // function may be used without arguments, such as pi
 }
 
 const eof = -1
 
-type lexer struct {
-	name        string    // used only for error reports
-	input       string    // the string being scanned
-	start       int       // start pos of this Item
-	pos         int       // current pos in input
-	width       int       // width of last rune read
-	items       chan Item // channel of scanned Item
+// Lexer scans a MATLAB expression into Items. Create it with NewLexer or NewStringLexer.
+type Lexer struct {
+	name        string        // used only for error reports
+	input       string        // the string being scanned
+	start       int           // start pos of this Item
+	line        int           // line of start
+	col         int           // column of start in runes
+	pos         int           // current pos in input
+	width       int           // width of last rune read
+	items       chan Item     // channel of scanned Item, used by Run
+	queue       []Item        // Items emitted and not yet delivered
+	qhead       int           // index of next Item in queue to deliver
+	done        chan struct{} // closed by Close to stop Run
+	closeOnce   sync.Once
+	ran         atomic.Bool // set by the first call to RunContext
 	state       stateFn
 	identifiers map[string]identifier
 	metaStack   Stack
+	brackets    Brackets
+	undefined   func(name string, groupFollows bool) ItemType // classifies undefined identifiers
+	plainErrors bool
+	errPrinter  *diag.Printer
 }
 
 // Creates new lexer with a name for error formatting
 // and gives it an input string to lex
-func NewStringLexer(name, input string) *lexer {
-	return lex(name, input)
+func NewStringLexer(name, input string) *Lexer {
+	return lex(name, input, &Options{Brackets: DefaultBrackets})
 }
 
 // run lexes the input by executing state functions until
-// the state is nil.
-func (l *lexer) Run() {
-	for state := lexStart; state != nil; {
-		state = state(l)
+// the state is nil, sending items to the channel returned by ItemChannel.
+// Run is meant to be run in its own goroutine and blocks until all items
+// are received or the lexer is closed. Use NextItem or Items to lex without a goroutine.
+func (l *Lexer) Run() {
+	l.RunContext(context.Background())
+}
+
+// RunContext is Run that also stops when ctx is done, in which case it returns ctx.Err().
+// The item channel is closed when RunContext returns, so that consumers ranging over it
+// stop too. It returns nil if all items were delivered or the lexer was closed.
+// A lexer may only be run once, later calls return an error.
+func (l *Lexer) RunContext(ctx context.Context) error {
+	if !l.ran.CompareAndSwap(false, true) {
+		return errors.New("lexer already run")
+	}
+	defer close(l.items) // No more tokens will be delivered.
+	for l.state != nil {
+		select {
+		case <-l.done:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+		l.state = l.state(l)
+		for l.qhead < len(l.queue) {
+			select {
+			case l.items <- l.dequeue():
+			case <-l.done:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
 	}
-	close(l.items) // No more tokens will be delivered.
+	return nil
+}
+
+// Close stops a running Run or RunContext and makes future calls return
+// immediately. It should be called by consumers that stop receiving items
+// before the channel is closed, so the goroutine running Run does not leak.
+// Close may be called more than once and from any goroutine.
+func (l *Lexer) Close() {
+	l.closeOnce.Do(func() { close(l.done) })
 }
 
-func (l *lexer) ItemChannel() (items <-chan Item) {
+func (l *Lexer) ItemChannel() (items <-chan Item) {
 	return l.items
 }
 
+// NextItem returns the next Item from the input, running the state
+// functions synchronously in the caller's goroutine. The last item is
+// either ItemEOF or ItemError, after which NextItem returns ItemEOF items.
+// NextItem must not be used together with Run.
+func (l *Lexer) NextItem() Item {
+	for l.qhead == len(l.queue) {
+		if l.state == nil {
+			return l.item(ItemEOF, "")
+		}
+		l.state = l.state(l)
+	}
+	return l.dequeue()
+}
+
+// Items returns an iterator over the items of the input which stops
+// after yielding ItemEOF or ItemError. Like NextItem it runs synchronously.
+//
+//	for item := range l.Items() {
+//		if item.Type() == ItemError {
+//			return errors.New(item.Value())
+//		}
+//		fmt.Println(item.Type(), item.Value())
+//	}
+func (l *Lexer) Items() iter.Seq[Item] {
+	return func(yield func(Item) bool) {
+		for {
+			item := l.NextItem()
+			if !yield(item) || item.typ == ItemEOF || item.typ == ItemError {
+				return
+			}
+		}
+	}
+}
+
 // Creates new variable identifier for lexer
 // if variable already exists throws error
-func (l *lexer) NewVariableID(value string) error {
+func (l *Lexer) NewVariableID(value string) error {
 	if l.getIDType(value) != idUndefined {
 		return fmt.Errorf("Variable already exists")
 	}
@@ -104,7 +200,7 @@ func (l *lexer) NewVariableID(value string) error {
 
 // Creates new function identifier for lexer
 // if function already exists throws error
-func (l *lexer) NewFunctionID(value string) error {
+func (l *Lexer) NewFunctionID(value string) error {
 	if l.getIDType(value) != idUndefined {
 		return fmt.Errorf("Function already exists")
 	}
@@ -122,41 +218,88 @@ func (i *Item) Value() string {
 	return i.val
 }
 
-// lex creates a new scanner for the input string.
-func lex(name, input string) *lexer {
-	l := &lexer{
+// returns the error message of an ItemError item without the position
+// and source snippet of its value, or the item's value for other items
+func (i *Item) Message() string {
+	if i.typ == ItemError {
+		return i.msg
+	}
+	return i.val
+}
+
+// returns byte offset of item's start in input
+func (i *Item) Start() int {
+	return i.start
+}
+
+// returns byte offset immediately after item's end in input
+func (i *Item) End() int {
+	return i.end
+}
+
+// returns 1-based line of item's start in input
+func (i *Item) Line() int {
+	return i.line
+}
+
+// returns 1-based column of item's start in input counted in runes
+func (i *Item) Col() int {
+	return i.col
+}
+
+// lex creates a new scanner for the input string. opts must be validated.
+func lex(name, input string, opts *Options) *Lexer {
+	bufsize := max(opts.BufferSize, 0)
+	if opts.BufferSize == 0 {
+		bufsize = 2 // Two items sufficient.
+	}
+	l := &Lexer{
 		name:        name,
 		input:       input,
+		line:        1,
+		col:         1,
 		state:       lexStart,
-		items:       make(chan Item, 2), // Two items sufficient.
+		items:       make(chan Item, bufsize),
+		done:        make(chan struct{}),
 		identifiers: make(map[string]identifier),
+		brackets:    opts.Brackets,
+		undefined:   opts.Classify,
+		plainErrors: opts.PlainErrors,
+		errPrinter:  opts.ErrorPrinter,
+	}
+	if l.errPrinter == nil {
+		l.errPrinter = &defaultErrPrinter
+	}
+	if l.undefined == nil && opts.AllowUndefined {
+		l.undefined = func(string, bool) ItemType { return ItemIdentifier }
 	}
 	return l
 }
 
-// nextItem returns the next Item from the input.
-// this for hiding the goroutine
-// actually does not use goroutine but does use channel
-func (l *lexer) nextItem() Item {
-	for {
-		select {
-		case item := <-l.items:
-			return item
-		default:
-			l.state = l.state(l)
-		}
+// emit passes an Item back to the client.
+func (l *Lexer) emit(t ItemType) {
+	l.queue = append(l.queue, l.item(t, l.input[l.start:l.pos]))
+	l.ignore()
+}
+
+// dequeue returns the next undelivered Item. The queue is reused once drained.
+func (l *Lexer) dequeue() Item {
+	item := l.queue[l.qhead]
+	l.qhead++
+	if l.qhead == len(l.queue) {
+		l.queue = l.queue[:0]
+		l.qhead = 0
 	}
-	panic("not reached")
+	return item
 }
 
-// emit passes an Item back to the client.
-func (l *lexer) emit(t ItemType) {
-	l.items <- Item{t, l.input[l.start:l.pos]}
-	l.start = l.pos
+// item returns an Item positioned at the pending input.
+func (l *Lexer) item(t ItemType, val string) Item {
+	return Item{typ: t, val: val, start: l.start, end: l.pos, line: l.line, col: l.col}
 }
 
 // advances cursor for next rune's width
-func (l *lexer) next() (rune rune) {
+func (l *Lexer) next() (rune rune) {
 	if l.pos >= len(l.input) {
 		l.width = 0
 		return eof
@@ -167,35 +310,60 @@ func (l *lexer) next() (rune rune) {
 	return rune
 }
 
-// terminates lexer and returns a formatted error message to lexer.items
-func (l *lexer) errorf(format string, args ...interface{}) stateFn {
+// terminates lexer and returns a diagnostic with the source snippet
+// of the pending input to lexer.items
+func (l *Lexer) errorf(format string, args ...interface{}) stateFn {
+	return l.errorNotes(nil, format, args...)
+}
+
+// errorNotes is errorf with notes pointing to related input, such as an opening bracket.
+func (l *Lexer) errorNotes(notes []diag.Note, format string, args ...interface{}) stateFn {
 	msg := fmt.Sprintf(format, args...)
-	start := l.pos - 10
-	if start < 0 {
-		start = 0
-	}
-	l.items <- Item{
-		ItemError,
-		fmt.Sprintf("Error at char %d: '%s'\n%s", l.pos, l.input[start:l.pos+1], msg),
+	plain := msg
+	if !l.plainErrors {
+		src := diag.NewSource(l.name, 0, []byte(l.input))
+		d := diag.Diagnostic{Span: diag.Span{Start: l.start, End: l.pos}, Msg: msg, Notes: notes}
+		msg = strings.TrimSuffix(string(l.errPrinter.Append(nil, src, &d)), "\n")
 	}
-	//panic("PANIC")
+	item := l.item(ItemError, msg)
+	item.msg = plain
+	l.queue = append(l.queue, item)
 	return nil
 }
 
 // ignore skips over the pending input before this point.
-func (l *lexer) ignore() {
+func (l *Lexer) ignore() {
+	for _, r := range l.input[l.start:l.pos] {
+		if r == '\n' {
+			l.line++
+			l.col = 1
+		} else {
+			l.col++
+		}
+	}
 	l.start = l.pos
 }
 
 // backup steps back one rune.
 // Can be called only once per call of next.
-func (l *lexer) backup() {
+func (l *Lexer) backup() {
 	l.pos -= l.width
 }
 
+// peekN returns the rune n runes after the next one without consuming input.
+func (l *Lexer) peekN(n int) rune {
+	pos := l.pos
+	for range n {
+		l.next()
+	}
+	r := l.next()
+	l.pos = pos
+	return r
+}
+
 // peek returns but does not consume
 // the next rune in the input.
-func (l *lexer) peek() rune {
+func (l *Lexer) peek() rune {
 	r := l.next()
 	l.backup()
 	return r
@@ -203,7 +371,7 @@ func (l *lexer) peek() rune {
 
 // accept consumes the next rune
 // if it's from the valid set.
-func (l *lexer) accept(valid string) bool {
+func (l *Lexer) accept(valid string) bool {
 	if strings.IndexRune(valid, l.next()) >= 0 {
 		return true
 	}
@@ -212,7 +380,7 @@ func (l *lexer) accept(valid string) bool {
 }
 
 // acceptRun consumes a run of runes from the valid set.
-func (l *lexer) acceptRun(valid string) bool {
+func (l *Lexer) acceptRun(valid string) bool {
 	var accepted bool
 	for strings.IndexRune(valid, l.next()) >= 0 {
 		accepted = true
@@ -224,7 +392,7 @@ func (l *lexer) acceptRun(valid string) bool {
 // adds new identifier to list of known identifiers.
 // returns false if identifier type does not match
 // with existing identifier's type
-func (l *lexer) idAdd(id identifier) bool {
+func (l *Lexer) idAdd(id identifier) bool {
 	currentID, present := l.identifiers[id.val]
 	if present && currentID.typ != id.typ {
 		return false
@@ -233,18 +401,10 @@ func (l *lexer) idAdd(id identifier) bool {
 	return true
 }
 
-func (l *lexer) getIDType(val string) idType {
+func (l *Lexer) getIDType(val string) idType {
 	id, present := l.identifiers[val]
 	if !present {
 		return idUndefined
 	}
 	return id.typ
 }
-
-func (l *lexer) emitJunk() bool {
-	if l.pos > l.start { //is token empty?
-		l.emit(ItemText) // emit whatever came before
-		return true
-	}
-	return false
-}
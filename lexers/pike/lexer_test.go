@@ -0,0 +1,379 @@
+package pike
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func lexAll(t *testing.T, input string, vars, funcs []string) (items []Item) {
+	t.Helper()
+	l := NewStringLexer("test.m", input)
+	for _, v := range vars {
+		if err := l.NewVariableID(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, f := range funcs {
+		if err := l.NewFunctionID(f); err != nil {
+			t.Fatal(err)
+		}
+	}
+	go l.Run()
+	for item := range l.ItemChannel() {
+		items = append(items, item)
+	}
+	return items
+}
+
+func TestItemPositions(t *testing.T) {
+	const input = "[X(1);\n]*[sin(U(2))*2.5]"
+	items := lexAll(t, input, []string{"X", "U"}, []string{"sin"})
+	want := []struct {
+		typ       ItemType
+		val       string
+		line, col int
+	}{
+		{ItemLeftMatMeta, "[", 1, 1},
+		{ItemVar, "X", 1, 2},
+		{ItemLeftIdxMeta, "(", 1, 3},
+		{ItemNumber, "1", 1, 4},
+		{ItemRightIdxMeta, ")", 1, 5},
+		{ItemSemiSep, ";", 1, 6},
+		{ItemRightMatMeta, "]", 2, 1},
+		{ItemOperator, "*", 2, 2},
+		{ItemLeftMatMeta, "[", 2, 3},
+		{ItemFunc, "sin", 2, 4},
+		{ItemLeftFuncMeta, "(", 2, 7},
+		{ItemVar, "U", 2, 8},
+		{ItemLeftIdxMeta, "(", 2, 9},
+		{ItemNumber, "2", 2, 10},
+		{ItemRightIdxMeta, ")", 2, 11},
+		{ItemRightFuncMeta, ")", 2, 12},
+		{ItemOperator, "*", 2, 13},
+		{ItemNumber, "2.5", 2, 14},
+		{ItemRightMatMeta, "]", 2, 17},
+		{ItemEOF, "", 2, 18},
+	}
+	if len(items) != len(want) {
+		t.Fatalf("got %d items, want %d: %v", len(items), len(want), items)
+	}
+	for i, w := range want {
+		it := items[i]
+		if it.Type() != w.typ || it.Value() != w.val || it.Line() != w.line || it.Col() != w.col {
+			t.Errorf("item %d: got %s %q at %d:%d, want %s %q at %d:%d", i,
+				it.Type(), it.Value(), it.Line(), it.Col(), w.typ, w.val, w.line, w.col)
+		}
+		if input[it.Start():it.End()] != it.Value() {
+			t.Errorf("item %d: offsets [%d:%d] do not match value %q", i, it.Start(), it.End(), it.Value())
+		}
+	}
+}
+
+func TestItemPositionsWhitespace(t *testing.T) {
+	type pos struct {
+		typ        ItemType
+		val        string
+		start, end int
+		line, col  int
+	}
+	tests := []struct {
+		input string
+		want  []pos
+	}{
+		{"\n  X(1)", []pos{
+			{ItemVar, "X", 3, 4, 2, 3}, {ItemLeftIdxMeta, "(", 4, 5, 2, 4}, {ItemNumber, "1", 5, 6, 2, 5},
+			{ItemRightIdxMeta, ")", 6, 7, 2, 6}, {ItemEOF, "", 7, 7, 2, 7},
+		}},
+		{"X(1) + sin(X(2))", []pos{
+			{ItemVar, "X", 0, 1, 1, 1}, {ItemLeftIdxMeta, "(", 1, 2, 1, 2}, {ItemNumber, "1", 2, 3, 1, 3},
+			{ItemRightIdxMeta, ")", 3, 4, 1, 4}, {ItemOperator, "+", 5, 6, 1, 6}, {ItemFunc, "sin", 7, 10, 1, 8},
+			{ItemLeftFuncMeta, "(", 10, 11, 1, 11}, {ItemVar, "X", 11, 12, 1, 12}, {ItemLeftIdxMeta, "(", 12, 13, 1, 13},
+			{ItemNumber, "2", 13, 14, 1, 14}, {ItemRightIdxMeta, ")", 14, 15, 1, 15}, {ItemRightFuncMeta, ")", 15, 16, 1, 16},
+			{ItemEOF, "", 16, 16, 1, 17},
+		}},
+		{"\t[ 1 ,\n .5 ]\n", []pos{
+			{ItemLeftMatMeta, "[", 1, 2, 1, 2}, {ItemNumber, "1", 3, 4, 1, 4}, {ItemCommaSep, ",", 5, 6, 1, 6},
+			{ItemNumber, ".5", 8, 10, 2, 2}, {ItemRightMatMeta, "]", 11, 12, 2, 5}, {ItemEOF, "", 13, 13, 3, 1},
+		}},
+		{"X(1)+#3", []pos{
+			{ItemVar, "X", 0, 1, 1, 1}, {ItemLeftIdxMeta, "(", 1, 2, 1, 2}, {ItemNumber, "1", 2, 3, 1, 3},
+			{ItemRightIdxMeta, ")", 3, 4, 1, 4}, {ItemOperator, "+", 4, 5, 1, 5},
+			{ItemError, "I found an unexpected character \"#\"", 5, 6, 1, 6},
+		}},
+	}
+	for _, test := range tests {
+		l, err := NewLexer("test.m", test.input, Options{Variables: []string{"X"}, Functions: []string{"sin"}, PlainErrors: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []pos
+		for it := range l.Items() {
+			got = append(got, pos{it.Type(), it.Value(), it.Start(), it.End(), it.Line(), it.Col()})
+		}
+		if !slices.Equal(got, test.want) {
+			t.Errorf("%q:\ngot  %v\nwant %v", test.input, got, test.want)
+		}
+	}
+}
+
+func TestMismatchedMeta(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{
+			input: "[X(1])",
+			want: "test.m:1:5: expected ')' closing '(' at 1:3, found ']'\n[X(1])\n    ^\n" +
+				"test.m:1:3: note: unclosed '(' opened here\n[X(1])\n  ^\n" +
+				"test.m:1:1: note: unclosed '[' opened here\n[X(1])\n^",
+		},
+		{
+			input: "[1)",
+			want: "test.m:1:3: expected ']' closing '[' at 1:1, found ')'\n[1)\n  ^\n" +
+				"test.m:1:1: note: unclosed '[' opened here\n[1)\n^",
+		},
+		{
+			input: "1)",
+			want:  "test.m:1:2: found ')' without opening '('\n1)\n ^",
+		},
+		{
+			input: "2]",
+			want:  "test.m:1:2: found ']' without opening '['\n2]\n ^",
+		},
+		{
+			input: "sin((1)",
+			want: "test.m:1:8: expected ')' closing '(' at 1:4, found EOF\nsin((1)\n       ^\n" +
+				"test.m:1:4: note: unclosed '(' opened here\nsin((1)\n   ^",
+		},
+	}
+	for _, test := range tests {
+		items := lexAll(t, test.input, []string{"X"}, []string{"sin"})
+		last := items[len(items)-1]
+		if last.Type() != ItemError {
+			t.Errorf("%q: expected error item, got %s %q", test.input, last.Type(), last.Value())
+			continue
+		}
+		if last.Value() != test.want {
+			t.Errorf("%q: got error\n%s\nwant\n%s", test.input, last.Value(), test.want)
+		}
+	}
+}
+
+func TestNoPanic(t *testing.T) {
+	// Lex every input of up to 4 characters from an alphabet covering all states.
+	const alphabet = "[]()X1s;,+.e\n"
+	var input []byte
+	var gen func(n int)
+	gen = func(n int) {
+		items := lexAll(t, string(input), []string{"X"}, []string{"s"})
+		last := items[len(items)-1]
+		if last.Type() != ItemEOF && last.Type() != ItemError {
+			t.Errorf("%q: lexing ended with %s", input, last.Type())
+		}
+		if n == 0 {
+			return
+		}
+		for i := range len(alphabet) {
+			input = append(input, alphabet[i])
+			gen(n - 1)
+			input = input[:len(input)-1]
+		}
+	}
+	gen(4)
+}
+
+func TestItems(t *testing.T) {
+	for _, input := range []string{"[X(1)*sin(X(2));2e-3]", "[X(1)"} {
+		want := lexAll(t, input, []string{"X"}, []string{"sin"})
+		l := NewStringLexer("test.m", input)
+		l.NewVariableID("X")
+		l.NewFunctionID("sin")
+		var got []Item
+		for item := range l.Items() {
+			got = append(got, item)
+		}
+		if !slices.Equal(got, want) {
+			t.Errorf("%q: Items got %v, want %v", input, got, want)
+		}
+		if item := l.NextItem(); item.Type() != ItemEOF {
+			t.Errorf("%q: want EOF after end of items, got %s", input, item.Type())
+		}
+	}
+}
+
+func TestRunStop(t *testing.T) {
+	input := "[" + strings.Repeat("X(1)+", 100) + "1]"
+	l := NewStringLexer("test.m", input)
+	l.NewVariableID("X")
+	done := make(chan struct{})
+	go func() {
+		l.Run()
+		close(done)
+	}()
+	<-l.ItemChannel() // Abandon run after first item.
+	l.Close()
+	<-done
+	for range l.ItemChannel() {
+	} // Channel must be closed.
+
+	l = NewStringLexer("test.m", input)
+	l.NewVariableID("X")
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error)
+	go func() { errc <- l.RunContext(ctx) }()
+	<-l.ItemChannel()
+	cancel()
+	if err := <-errc; err != context.Canceled {
+		t.Errorf("want context.Canceled, got %v", err)
+	}
+	if err := l.RunContext(context.Background()); err == nil {
+		t.Error("expected error running lexer twice")
+	}
+
+	// A done context stops the lexer before lexing, even with room in the channel.
+	l = NewStringLexer("test.m", input)
+	l.NewVariableID("X")
+	if err := l.RunContext(ctx); err != context.Canceled {
+		t.Errorf("want context.Canceled, got %v", err)
+	}
+	if _, ok := <-l.ItemChannel(); ok {
+		t.Error("expected no items with done context")
+	}
+}
+
+func TestOptions(t *testing.T) {
+	l, err := NewLexer("test.m", "{X<1>;sin<2>}", Options{
+		Variables: []string{"X"},
+		Functions: []string{"sin"},
+		Brackets:  Brackets{MatrixOpen: '{', MatrixClose: '}', GroupOpen: '<', GroupClose: '>'},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []ItemType
+	for item := range l.Items() {
+		got = append(got, item.Type())
+	}
+	want := []ItemType{ItemLeftMatMeta, ItemVar, ItemLeftIdxMeta, ItemNumber, ItemRightIdxMeta, ItemSemiSep,
+		ItemFunc, ItemLeftFuncMeta, ItemNumber, ItemRightFuncMeta, ItemRightMatMeta, ItemEOF}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	l, err = NewLexer("test.m", "[1)", Options{PlainErrors: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var last Item
+	for last = range l.Items() {
+	}
+	if last.Type() != ItemError || last.Value() != "expected ']' closing '[' at 1:1, found ')'" || last.Col() != 3 {
+		t.Errorf("got plain error %q at column %d", last.Value(), last.Col())
+	}
+
+	for _, opts := range []Options{
+		{Brackets: Brackets{MatrixOpen: '[', MatrixClose: ']', GroupOpen: '(', GroupClose: '+'}},
+		{Brackets: Brackets{MatrixOpen: '(', MatrixClose: ')', GroupOpen: '(', GroupClose: ')'}},
+		{Brackets: Brackets{MatrixOpen: '['}},
+		{Variables: []string{"X"}, Functions: []string{"X"}},
+	} {
+		if _, err := NewLexer("test.m", "", opts); err == nil {
+			t.Errorf("expected error for options %+v", opts)
+		}
+	}
+}
+
+func TestUndefinedIdentifiers(t *testing.T) {
+	const input = "[a(1)*f(b)+X]"
+	types := func(opts Options) (got []ItemType) {
+		t.Helper()
+		l, err := NewLexer("test.m", input, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for item := range l.Items() {
+			got = append(got, item.Type())
+		}
+		return got
+	}
+	got := types(Options{AllowUndefined: true})
+	want := []ItemType{ItemLeftMatMeta, ItemIdentifier, ItemLeftPemdas, ItemNumber, ItemRightPemdas, ItemOperator,
+		ItemIdentifier, ItemLeftPemdas, ItemIdentifier, ItemRightPemdas, ItemOperator, ItemIdentifier, ItemRightMatMeta, ItemEOF}
+	if !slices.Equal(got, want) {
+		t.Errorf("AllowUndefined: got %v, want %v", got, want)
+	}
+
+	var names []string
+	got = types(Options{
+		Variables: []string{"X"},
+		Classify: func(name string, groupFollows bool) ItemType {
+			names = append(names, name)
+			if name == "f" && groupFollows {
+				return ItemFunc
+			}
+			return ItemVar
+		},
+	})
+	want = []ItemType{ItemLeftMatMeta, ItemVar, ItemLeftIdxMeta, ItemNumber, ItemRightIdxMeta, ItemOperator,
+		ItemFunc, ItemLeftFuncMeta, ItemVar, ItemRightFuncMeta, ItemOperator, ItemVar, ItemRightMatMeta, ItemEOF}
+	if !slices.Equal(got, want) {
+		t.Errorf("Classify: got %v, want %v", got, want)
+	}
+	if !slices.Equal(names, []string{"a", "f", "b"}) {
+		t.Errorf("Classify called for %q, want only undefined identifiers", names)
+	}
+
+	got = types(Options{})
+	if got[len(got)-1] != ItemError {
+		t.Error("want error for undefined identifier by default")
+	}
+}
+
+func TestBuiltins(t *testing.T) {
+	l, err := NewLexer("test.m", "[atan2(x,max(1,2));min]", Options{Variables: []string{"x", "min"}, Builtins: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []ItemType
+	for item := range l.Items() {
+		got = append(got, item.Type())
+	}
+	want := []ItemType{ItemLeftMatMeta, ItemFunc, ItemLeftFuncMeta, ItemVar, ItemCommaSep, ItemFunc, ItemLeftFuncMeta,
+		ItemNumber, ItemCommaSep, ItemNumber, ItemRightFuncMeta, ItemRightFuncMeta, ItemSemiSep, ItemVar, ItemRightMatMeta, ItemEOF}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	f, ok := LookupFunc("atan2")
+	if !ok || !f.AcceptsArgs(2) || f.AcceptsArgs(1) {
+		t.Errorf("atan2 lookup got %+v %v", f, ok)
+	}
+	if f, _ := LookupFunc("zeros"); !f.AcceptsArgs(0) || !f.AcceptsArgs(5) {
+		t.Errorf("zeros should be variadic, got %+v", f)
+	}
+
+	// Functions taking no arguments may be used without parentheses.
+	for _, test := range []struct {
+		input string
+		want  []ItemType
+	}{
+		{"2*pi", []ItemType{ItemNumber, ItemOperator, ItemIdentifier, ItemEOF}},
+		{"eps", []ItemType{ItemIdentifier, ItemEOF}},
+		{"pi(2)", []ItemType{ItemFunc, ItemLeftFuncMeta, ItemNumber, ItemRightFuncMeta, ItemEOF}},
+		{"[ones;pi]", []ItemType{ItemLeftMatMeta, ItemIdentifier, ItemSemiSep, ItemIdentifier, ItemRightMatMeta, ItemEOF}},
+		{"sin", []ItemType{ItemFunc, ItemError}},
+	} {
+		l, err := NewLexer("test.m", test.input, Options{Builtins: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = got[:0]
+		for item := range l.Items() {
+			got = append(got, item.Type())
+		}
+		if !slices.Equal(got, test.want) {
+			t.Errorf("%q: got %v, want %v", test.input, got, test.want)
+		}
+	}
+}
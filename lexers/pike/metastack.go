@@ -1,56 +1,84 @@
 package pike
 
+import "github.com/soypat/lexer/diag"
+
 // Credit to https://github.com/golang-collections/collections
 
-// these must be modified at metaWrap too
 const (
-	leftMatMeta   = "["
-	rightMatMeta  = "]"
-	leftFuncMeta  = "("
-	rightFuncMeta = ")"
-	leftIdxMeta   = "("
-	rightIdxMeta  = ")"
-	leftPemdas    = "("
-	rightPemdas   = ")"
-	semiSep       = ";"
-	commaSep      = ","
+	semiSep  = ";"
+	commaSep = ","
 )
 
-// pushes meta Item to stack. If there is a matching opposite
-// at top of list then it drops meta at top of stack and does not push
-func (l *lexer) metaWrap(item ItemType) {
+// pushes opening meta Item at pending input to stack. Closing metas pop
+// the matching opener at top of stack. If the opener does not match
+// it sends an error item and returns false, the lexer must then stop.
+func (l *Lexer) metaWrap(item ItemType) bool {
+	var opener ItemType
 	switch item {
 	case ItemLeftIdxMeta, ItemLeftFuncMeta, ItemLeftMatMeta, ItemLeftPemdas:
-		l.metaStack.Push(item)
+		l.metaStack.Push(l.item(item, l.input[l.start:l.pos]))
+		return true
 	case ItemRightIdxMeta:
-		if l.metaStack.Pop() != ItemLeftIdxMeta {
-			panic("I found mismatched right index meta")
-		}
+		opener = ItemLeftIdxMeta
 	case ItemRightFuncMeta:
-		if l.metaStack.Pop() != ItemLeftFuncMeta {
-			panic("I found mismatched right function meta")
-		}
+		opener = ItemLeftFuncMeta
 	case ItemRightMatMeta:
-		if l.metaStack.Pop() != ItemLeftMatMeta {
-			panic("I found mismatched right matrix meta")
-		}
+		opener = ItemLeftMatMeta
 	case ItemRightPemdas:
-		if l.metaStack.Pop() != ItemLeftPemdas {
-			panic("I found mismatched right group meta (pemdas)")
-		}
+		opener = ItemLeftPemdas
+	default:
+		return true
+	}
+	if l.metaCurrent() != opener {
+		l.metaMismatch(item)
+		return false
 	}
+	l.metaStack.Pop()
+	return true
 }
 
-// returns meta Item at top of stack without modifying stack
-func (l *lexer) metaCurrent() (it ItemType) {
-	I := l.metaStack.Peek()
-	switch I.(type) {
-	case ItemType:
-		return I.(ItemType)
-	case nil:
+// returns meta Item type at top of stack without modifying stack
+func (l *Lexer) metaCurrent() (it ItemType) {
+	top, ok := l.metaStack.Peek().(Item)
+	if !ok {
 		return ItemNil
 	}
-	panic("I should have gotten ItemType in stack!")
+	return top.typ
+}
+
+// metaMismatch sends an error for a closing meta at pending input, or EOF if closer
+// is ItemNil, that does not close the opener at top of stack. The error notes point
+// to all unclosed openers.
+func (l *Lexer) metaMismatch(closer ItemType) stateFn {
+	found := "EOF"
+	if closer != ItemNil {
+		found = "'" + l.input[l.start:l.pos] + "'"
+	}
+	top, ok := l.metaStack.Peek().(Item)
+	if !ok {
+		opening, _ := l.metaPair(closer)
+		return l.errorf("found %s without opening %q", found, opening)
+	}
+	var notes []diag.Note
+	for n := l.metaStack.top; n != nil; n = n.prev {
+		if opener, ok := n.value.(Item); ok {
+			notes = append(notes, diag.Note{
+				Span: diag.Span{Start: opener.start, End: opener.end},
+				Msg:  "unclosed '" + opener.val + "' opened here",
+			})
+		}
+	}
+	_, closing := l.metaPair(top.typ)
+	return l.errorNotes(notes, "expected %q closing '%s' at %d:%d, found %s",
+		closing, top.val, top.line, top.col, found)
+}
+
+// metaPair returns the opening and closing bracket of a meta item type.
+func (l *Lexer) metaPair(t ItemType) (opening, closing rune) {
+	if t == ItemLeftMatMeta || t == ItemRightMatMeta {
+		return l.brackets.MatrixOpen, l.brackets.MatrixClose
+	}
+	return l.brackets.GroupOpen, l.brackets.GroupClose
 }
 
 type (
@@ -0,0 +1,99 @@
+package pike
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/soypat/lexer/diag"
+)
+
+// Options configures a Lexer created with NewLexer. The zero value is the
+// configuration used by NewStringLexer.
+type Options struct {
+	// BufferSize is the capacity of the channel of items sent by Run.
+	// If zero a capacity of 2 is used, if negative the channel is unbuffered.
+	BufferSize int
+	// Variables and Functions are identifiers known to the lexer from the start.
+	// More may be added with NewVariableID and NewFunctionID.
+	Variables, Functions []string
+	// Builtins registers the built-in functions, see Builtins, which are not
+	// already in Variables or Functions. Built-in functions taking no arguments,
+	// such as pi, lex as ItemIdentifier when not followed by Brackets.GroupOpen.
+	Builtins bool
+	// AllowUndefined makes identifiers that are neither variables nor functions
+	// lex as ItemIdentifier instead of an error, leaving their classification to the parser.
+	AllowUndefined bool
+	// Classify, if set, classifies identifiers that are neither variables nor functions
+	// as they are lexed. groupFollows reports whether the identifier is immediately
+	// followed by Brackets.GroupOpen. It must return ItemVar, ItemFunc or ItemIdentifier,
+	// or ItemError to report the identifier as undefined. Classify takes precedence over AllowUndefined.
+	Classify func(name string, groupFollows bool) ItemType
+	// Brackets are the bracket characters. If zero DefaultBrackets is used.
+	Brackets Brackets
+	// PlainErrors makes ItemError values hold only the error message instead
+	// of a diagnostic with the source snippet. The error position is available
+	// through the item's accessors.
+	PlainErrors bool
+	// ErrorPrinter renders the diagnostic of error items. If nil diagnostics
+	// are rendered without color and long lines are cut to 80 columns.
+	ErrorPrinter *diag.Printer
+}
+
+// Brackets are the characters delimiting matrices and groups.
+type Brackets struct {
+	MatrixOpen, MatrixClose rune // Matrix literal delimiters.
+	GroupOpen, GroupClose   rune // Delimiters of function arguments, indexing and grouping.
+}
+
+// DefaultBrackets are MATLAB's brackets.
+var DefaultBrackets = Brackets{MatrixOpen: '[', MatrixClose: ']', GroupOpen: '(', GroupClose: ')'}
+
+// defaultErrPrinter renders error items. Lines are cut since matrix expressions are often a single long line.
+var defaultErrPrinter = diag.Printer{MaxLineWidth: 80}
+
+// NewLexer creates a new lexer of input configured by opts.
+// name is used for error formatting.
+func NewLexer(name, input string, opts Options) (*Lexer, error) {
+	if opts.Brackets == (Brackets{}) {
+		opts.Brackets = DefaultBrackets
+	} else if err := opts.Brackets.validate(); err != nil {
+		return nil, err
+	}
+	l := lex(name, input, &opts)
+	for _, v := range opts.Variables {
+		if err := l.NewVariableID(v); err != nil {
+			return nil, fmt.Errorf("%w: %q", err, v)
+		}
+	}
+	for _, f := range opts.Functions {
+		if err := l.NewFunctionID(f); err != nil {
+			return nil, fmt.Errorf("%w: %q", err, f)
+		}
+	}
+	if opts.Builtins {
+		for _, f := range builtinFuncs {
+			if l.getIDType(f.Name) == idUndefined {
+				l.idAdd(identifier{typ: idFunc, val: f.Name, nullary: f.MinArgs == 0})
+			}
+		}
+	}
+	return l, nil
+}
+
+func (b Brackets) validate() error {
+	chars := []rune{b.MatrixOpen, b.MatrixClose, b.GroupOpen, b.GroupClose}
+	for i, c := range chars {
+		switch {
+		case c <= 0:
+			return errors.New("brackets must all be set")
+		case isNumeric(c) || isASCIIAlpha(c) || isOperator(c) || isSeparator(c) || isSpace(c) || c == '.' || c == '_':
+			return fmt.Errorf("bracket %q conflicts with other input", c)
+		}
+		for _, prev := range chars[:i] {
+			if c == prev {
+				return fmt.Errorf("duplicate bracket %q", c)
+			}
+		}
+	}
+	return nil
+}
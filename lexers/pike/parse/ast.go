@@ -0,0 +1,126 @@
+package parse
+
+// Expr is a node of the abstract syntax tree of a MATLAB expression.
+// Positions are byte offsets in the lexed input.
+type Expr interface {
+	Pos() int // Start of expression.
+	End() int // Position immediately after the expression.
+	exprNode()
+}
+
+// IdentKind is the classification of an identifier by the lexer.
+type IdentKind uint8
+
+const (
+	IdentUnknown IdentKind = iota // Not defined, lexed with pike.Options.AllowUndefined.
+	IdentVar                      // Variable.
+	IdentFunc                     // Function.
+)
+
+type (
+	// Number is a numeric literal such as 42, 2.5e-3 or 3i.
+	Number struct {
+		ValuePos int
+		Value    string // Literal as in input.
+		Imag     bool   // Literal has imaginary suffix i.
+	}
+
+	// Ident is an identifier.
+	Ident struct {
+		NamePos int
+		Name    string
+		Kind    IdentKind
+	}
+
+	// Unary is a unary plus or minus expression.
+	Unary struct {
+		OpPos int
+		Op    byte // '+' or '-'.
+		X     Expr
+	}
+
+	// Binary is a binary arithmetic expression.
+	Binary struct {
+		X     Expr
+		OpPos int
+		Op    byte // One of '+', '-', '*', '/' or '^'.
+		Y     Expr
+	}
+
+	// Range is a colon expression Start:Stop or Start:Step:Stop.
+	Range struct {
+		Start Expr
+		Step  Expr // Nil if absent.
+		Stop  Expr
+	}
+
+	// Colon is a lone colon index selecting all elements, as in X(:).
+	Colon struct {
+		ColonPos int
+	}
+
+	// Call is a function call. Identifiers of unknown kind followed
+	// by arguments also parse as a Call, which may be an indexing.
+	Call struct {
+		Func   *Ident
+		Lparen int
+		Args   []Expr
+		Rparen int
+	}
+
+	// Index is an indexing of a variable, as in X(4).
+	Index struct {
+		X      *Ident
+		Lparen int
+		Args   []Expr
+		Rparen int
+	}
+
+	// Paren is a parenthesized expression.
+	Paren struct {
+		Lparen int
+		X      Expr
+		Rparen int
+	}
+
+	// Matrix is a matrix literal. Rows are separated by semicolons and
+	// elements in a row by commas.
+	Matrix struct {
+		Lbrack int
+		Rows   [][]Expr
+		Rbrack int
+	}
+)
+
+func (x *Number) Pos() int { return x.ValuePos }
+func (x *Ident) Pos() int  { return x.NamePos }
+func (x *Unary) Pos() int  { return x.OpPos }
+func (x *Binary) Pos() int { return x.X.Pos() }
+func (x *Range) Pos() int  { return x.Start.Pos() }
+func (x *Colon) Pos() int  { return x.ColonPos }
+func (x *Call) Pos() int   { return x.Func.Pos() }
+func (x *Index) Pos() int  { return x.X.Pos() }
+func (x *Paren) Pos() int  { return x.Lparen }
+func (x *Matrix) Pos() int { return x.Lbrack }
+
+func (x *Number) End() int { return x.ValuePos + len(x.Value) }
+func (x *Ident) End() int  { return x.NamePos + len(x.Name) }
+func (x *Unary) End() int  { return x.X.End() }
+func (x *Binary) End() int { return x.Y.End() }
+func (x *Range) End() int  { return x.Stop.End() }
+func (x *Colon) End() int  { return x.ColonPos + 1 }
+func (x *Call) End() int   { return x.Rparen + 1 }
+func (x *Index) End() int  { return x.Rparen + 1 }
+func (x *Paren) End() int  { return x.Rparen + 1 }
+func (x *Matrix) End() int { return x.Rbrack + 1 }
+
+func (*Number) exprNode() {}
+func (*Ident) exprNode()  {}
+func (*Unary) exprNode()  {}
+func (*Binary) exprNode() {}
+func (*Range) exprNode()  {}
+func (*Colon) exprNode()  {}
+func (*Call) exprNode()   {}
+func (*Index) exprNode()  {}
+func (*Paren) exprNode()  {}
+func (*Matrix) exprNode() {}
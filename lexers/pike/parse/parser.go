@@ -0,0 +1,368 @@
+// Package parse implements a parser of MATLAB expressions lexed by pike.
+// It builds an abstract syntax tree of matrices, function calls, variable
+// indexing, grouping, ranges and arithmetic with MATLAB operator precedence,
+// from lowest to highest:
+//
+//	:        range, start:stop or start:step:stop
+//	+ -      addition and subtraction, left associative
+//	* /      multiplication and division, left associative
+//	+ -      unary plus and minus
+//	^        power, left associative
+//
+// so that -2^2 is -(2^2) and 2^3^2 is (2^3)^2. As in MATLAB signs following ^
+// apply to the exponent only: 2^-1 is 2^(-1) and 2^-3^2 is (2^-3)^2.
+package parse
+
+import (
+	"fmt"
+	"iter"
+	"strings"
+
+	"github.com/soypat/lexer/lexers/pike"
+)
+
+// Error is a parsing error positioned in the lexed input.
+type Error struct {
+	Start, End int // Byte offsets of the offending input.
+	Line, Col  int // 1-based line and column of Start.
+	Msg        string
+}
+
+// Error returns the error formatted as "line:col: message".
+func (e *Error) Error() string {
+	return fmt.Sprintf("%d:%d: %s", e.Line, e.Col, e.Msg)
+}
+
+// Operator precedences.
+const (
+	precLowest = iota
+	precRange
+	precAdd
+	precMul
+	precUnary
+	precPow
+)
+
+// token is a lexed item.
+type token struct {
+	typ        pike.ItemType
+	val        string
+	start, end int
+	line, col  int
+	space      bool // Preceded by whitespace.
+}
+
+type parser struct {
+	next   func() (pike.Item, bool)
+	tok    token  // Current token.
+	ahead  *token // Token after the current one if peeked.
+	spaces bool   // Whitespace separates matrix elements.
+	err    *Error
+}
+
+// Parse parses a single expression from items, such as those returned by [pike.Lexer.Items].
+// Error items of the lexer are returned as an [*Error].
+func Parse(items iter.Seq[pike.Item]) (Expr, error) {
+	next, stop := iter.Pull(items)
+	defer stop()
+	p := &parser{next: next}
+	p.advance()
+	x := p.parseExpr(precLowest)
+	if p.err == nil && p.tok.typ != pike.ItemEOF {
+		p.errorf("unexpected %s after expression", p.tok.describe())
+	}
+	if p.err != nil {
+		return nil, p.err
+	}
+	return x, nil
+}
+
+// advance reads the next token.
+func (p *parser) advance() {
+	if p.err != nil {
+		return
+	}
+	if p.ahead != nil {
+		p.tok, p.ahead = *p.ahead, nil
+	} else {
+		p.tok = p.read()
+	}
+	if p.tok.typ == pike.ItemError {
+		p.err = &Error{Start: p.tok.start, End: p.tok.end, Line: p.tok.line, Col: p.tok.col, Msg: p.tok.val}
+	}
+}
+
+// peek returns the token after the current one without consuming it.
+func (p *parser) peek() token {
+	if p.ahead == nil {
+		tok := p.read()
+		p.ahead = &tok
+	}
+	return *p.ahead
+}
+
+// read returns the next lexed token.
+func (p *parser) read() token {
+	item, ok := p.next()
+	if !ok {
+		return token{typ: pike.ItemEOF, start: p.tok.end, end: p.tok.end, line: p.tok.line, col: p.tok.col}
+	}
+	tok := token{typ: item.Type(), val: item.Value(), start: item.Start(), end: item.End(), line: item.Line(), col: item.Col()}
+	if tok.typ == pike.ItemError {
+		tok.val = item.Message() // Position is kept apart from the message.
+	}
+	tok.space = tok.start > p.tok.end // The lexer skips whitespace between items.
+	return tok
+}
+
+func (tok token) describe() string {
+	switch tok.typ {
+	case pike.ItemEOF:
+		return "end of input"
+	}
+	return fmt.Sprintf("%q", tok.val)
+}
+
+func (p *parser) errorf(format string, args ...any) {
+	if p.err != nil {
+		return // Keep first error.
+	}
+	p.err = &Error{Start: p.tok.start, End: p.tok.end, Line: p.tok.line, Col: p.tok.col, Msg: fmt.Sprintf(format, args...)}
+}
+
+// expect consumes the current token if it is of type typ and returns its start.
+func (p *parser) expect(typ pike.ItemType, what string) int {
+	pos := p.tok.start
+	if p.tok.typ != typ {
+		p.errorf("expected %s, found %s", what, p.tok.describe())
+	}
+	p.advance()
+	return pos
+}
+
+// binaryPrec returns the precedence of the binary operator at the current token
+// or precLowest if it is not one.
+func (p *parser) binaryPrec() int {
+	switch p.tok.typ {
+	case pike.ItemColonOp:
+		return precRange
+	case pike.ItemOperator:
+		switch p.tok.val {
+		case "+", "-":
+			return precAdd
+		case "*", "/":
+			return precMul
+		case "^":
+			return precPow
+		}
+	}
+	return precLowest
+}
+
+// parseExpr parses an expression of operators with precedence of at least minPrec.
+func (p *parser) parseExpr(minPrec int) Expr {
+	x := p.parseUnary()
+	for p.err == nil {
+		prec := p.binaryPrec()
+		if prec == precLowest || prec < minPrec || p.elementStart() {
+			return x
+		}
+		if prec == precRange {
+			x = p.parseRange(x)
+			continue
+		}
+		op, opPos := p.tok.val[0], p.tok.start
+		p.advance()
+		var y Expr
+		if op == '^' {
+			y = p.parseExponent()
+		} else {
+			y = p.parseExpr(prec + 1) // Left associative.
+		}
+		x = &Binary{X: x, OpPos: opPos, Op: op, Y: y}
+	}
+	return x
+}
+
+// elementStart reports whether the current token starts a matrix element
+// separated by whitespace from the previous one. As in MATLAB a sign preceded
+// but not followed by whitespace starts an element so that [1 -2] has two
+// elements while [1 - 2] and [1-2] have one.
+func (p *parser) elementStart() bool {
+	if !p.spaces || !p.tok.space {
+		return false
+	}
+	switch p.tok.typ {
+	case pike.ItemNumber, pike.ItemVar, pike.ItemFunc, pike.ItemIdentifier, pike.ItemLeftPemdas, pike.ItemLeftMatMeta:
+		return true
+	case pike.ItemOperator:
+		next := p.peek()
+		return (p.tok.val == "+" || p.tok.val == "-") && !next.space
+	}
+	return false
+}
+
+// parseRange parses the rest of a range after its start. The current token is the colon.
+func (p *parser) parseRange(start Expr) Expr {
+	p.advance()
+	r := &Range{Start: start, Stop: p.parseExpr(precRange + 1)}
+	if p.tok.typ == pike.ItemColonOp {
+		p.advance()
+		r.Step = r.Stop
+		r.Stop = p.parseExpr(precRange + 1)
+	}
+	if p.tok.typ == pike.ItemColonOp {
+		p.errorf("range has too many colons")
+	}
+	return r
+}
+
+func (p *parser) parseUnary() Expr {
+	if p.tok.typ == pike.ItemOperator && (p.tok.val == "+" || p.tok.val == "-") {
+		op, opPos := p.tok.val[0], p.tok.start
+		p.advance()
+		return &Unary{OpPos: opPos, Op: op, X: p.parseExpr(precUnary)}
+	}
+	return p.parsePrimary()
+}
+
+// parseExponent parses the right operand of ^, a primary expression with
+// optional signs which apply to it only, as in MATLAB: 2^-3^2 is (2^-3)^2.
+func (p *parser) parseExponent() Expr {
+	if p.tok.typ == pike.ItemOperator && (p.tok.val == "+" || p.tok.val == "-") {
+		op, opPos := p.tok.val[0], p.tok.start
+		p.advance()
+		return &Unary{OpPos: opPos, Op: op, X: p.parseExponent()}
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() Expr {
+	tok := p.tok
+	switch tok.typ {
+	case pike.ItemNumber:
+		p.advance()
+		return &Number{ValuePos: tok.start, Value: tok.val, Imag: strings.HasSuffix(tok.val, "i")}
+	case pike.ItemVar:
+		p.advance()
+		id := &Ident{NamePos: tok.start, Name: tok.val, Kind: IdentVar}
+		if p.tok.typ != pike.ItemLeftIdxMeta {
+			return id
+		}
+		lparen := p.tok.start
+		args, rparen := p.parseArgs(pike.ItemRightIdxMeta)
+		return &Index{X: id, Lparen: lparen, Args: args, Rparen: rparen}
+	case pike.ItemFunc:
+		p.advance()
+		id := &Ident{NamePos: tok.start, Name: tok.val, Kind: IdentFunc}
+		lparen := p.tok.start
+		if p.tok.typ != pike.ItemLeftFuncMeta {
+			p.errorf("expected '(' after function %s, found %s", id.Name, p.tok.describe())
+			return id
+		}
+		args, rparen := p.parseArgs(pike.ItemRightFuncMeta)
+		return &Call{Func: id, Lparen: lparen, Args: args, Rparen: rparen}
+	case pike.ItemIdentifier:
+		p.advance()
+		id := &Ident{NamePos: tok.start, Name: tok.val, Kind: IdentUnknown}
+		if p.tok.typ != pike.ItemLeftPemdas || p.tok.start != id.End() {
+			return id
+		}
+		lparen := p.tok.start
+		args, rparen := p.parseArgs(pike.ItemRightPemdas)
+		return &Call{Func: id, Lparen: lparen, Args: args, Rparen: rparen}
+	case pike.ItemLeftPemdas:
+		restore := p.nest(false)
+		p.advance()
+		x := p.parseExpr(precLowest)
+		restore()
+		rparen := p.expect(pike.ItemRightPemdas, "')'")
+		return &Paren{Lparen: tok.start, X: x, Rparen: rparen}
+	case pike.ItemLeftMatMeta:
+		return p.parseMatrix()
+	}
+	p.errorf("expected expression, found %s", tok.describe())
+	return &Number{ValuePos: tok.start} // Placeholder so callers need not check for nil.
+}
+
+// parseArgs parses comma separated arguments. The current token is the opening parenthesis.
+func (p *parser) parseArgs(closing pike.ItemType) (args []Expr, rparen int) {
+	defer p.nest(false)()
+	p.advance()
+	if p.tok.typ == closing {
+		rparen = p.tok.start
+		p.advance()
+		return nil, rparen
+	}
+	for p.err == nil {
+		args = append(args, p.parseArg(closing))
+		if p.tok.typ != pike.ItemCommaSep {
+			break
+		}
+		p.advance()
+	}
+	rparen = p.expect(closing, "',' or ')'")
+	return args, rparen
+}
+
+// parseArg parses an argument which may be a lone colon.
+func (p *parser) parseArg(closing pike.ItemType) Expr {
+	if p.tok.typ == pike.ItemColonOp {
+		pos := p.tok.start
+		p.advance()
+		if p.tok.typ == pike.ItemCommaSep || p.tok.typ == closing {
+			return &Colon{ColonPos: pos}
+		}
+		p.errorf("expected ',' or ')' after lone ':', found %s", p.tok.describe())
+		return &Colon{ColonPos: pos}
+	}
+	return p.parseExpr(precLowest)
+}
+
+// nest sets whether whitespace separates matrix elements in a nested
+// expression and returns a function restoring the previous setting.
+func (p *parser) nest(spaces bool) (restore func()) {
+	prev := p.spaces
+	p.spaces = spaces
+	return func() { p.spaces = prev }
+}
+
+// parseMatrix parses a matrix literal. The current token is the opening bracket.
+// Elements are separated by commas or whitespace.
+// Empty rows, as in [1;] or [;], are ignored as in MATLAB.
+func (p *parser) parseMatrix() Expr {
+	m := &Matrix{Lbrack: p.tok.start}
+	defer p.nest(true)()
+	p.advance()
+	var row []Expr
+	for p.err == nil {
+		switch p.tok.typ {
+		case pike.ItemRightMatMeta:
+			if len(row) > 0 {
+				m.Rows = append(m.Rows, row)
+			}
+			m.Rbrack = p.tok.start
+			p.advance()
+			return m
+		case pike.ItemSemiSep:
+			if len(row) > 0 {
+				m.Rows = append(m.Rows, row)
+				row = nil
+			}
+			p.advance()
+			continue
+		}
+		row = append(row, p.parseExpr(precLowest))
+		switch p.tok.typ {
+		case pike.ItemCommaSep:
+			p.advance()
+		case pike.ItemSemiSep, pike.ItemRightMatMeta:
+		default:
+			if p.elementStart() {
+				continue
+			}
+			p.errorf("expected ',', ';' or ']' in matrix, found %s", p.tok.describe())
+		}
+	}
+	return m
+}
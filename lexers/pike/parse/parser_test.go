@@ -0,0 +1,285 @@
+package parse
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/soypat/lexer/lexers/pike"
+)
+
+func parseString(t *testing.T, input string) (Expr, error) {
+	t.Helper()
+	l, err := pike.NewLexer("test.m", input, pike.Options{
+		Variables: []string{"X", "U"},
+		Builtins:  true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Parse(l.Items())
+}
+
+// sexpr returns x as a fully parenthesized prefix expression.
+func sexpr(x Expr) string {
+	var b strings.Builder
+	var walk func(x Expr)
+	list := func(head string, args []Expr) {
+		b.WriteString("(" + head)
+		for _, arg := range args {
+			b.WriteByte(' ')
+			walk(arg)
+		}
+		b.WriteByte(')')
+	}
+	walk = func(x Expr) {
+		switch x := x.(type) {
+		case *Number:
+			b.WriteString(x.Value)
+		case *Ident:
+			b.WriteString(x.Name)
+		case *Colon:
+			b.WriteString(":")
+		case *Unary:
+			list(string(x.Op), []Expr{x.X})
+		case *Binary:
+			list(string(x.Op), []Expr{x.X, x.Y})
+		case *Range:
+			if x.Step != nil {
+				list("range", []Expr{x.Start, x.Step, x.Stop})
+			} else {
+				list("range", []Expr{x.Start, x.Stop})
+			}
+		case *Call:
+			list("call "+x.Func.Name, x.Args)
+		case *Index:
+			list("index "+x.X.Name, x.Args)
+		case *Paren:
+			list("paren", []Expr{x.X})
+		case *Matrix:
+			b.WriteString("[")
+			for i, row := range x.Rows {
+				if i > 0 {
+					b.WriteString("; ")
+				}
+				for j, elem := range row {
+					if j > 0 {
+						b.WriteString(" ")
+					}
+					walk(elem)
+				}
+			}
+			b.WriteString("]")
+		}
+	}
+	walk(x)
+	return b.String()
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"1+2*3", "(+ 1 (* 2 3))"},
+		{"1-2-3", "(- (- 1 2) 3)"},
+		{"8/4/2", "(/ (/ 8 4) 2)"},
+		{"2^3^2", "(^ (^ 2 3) 2)"},
+		{"2^-3^2", "(^ (^ 2 (- 3)) 2)"},
+		{"2^(3^2)", "(^ 2 (paren (^ 3 2)))"},
+		{"-2^2", "(- (^ 2 2))"},
+		{"2^-1", "(^ 2 (- 1))"},
+		{"-X(1)*2", "(* (- (index X 1)) 2)"},
+		{"(1+2)*3", "(* (paren (+ 1 2)) 3)"},
+		{"1:3", "(range 1 3)"},
+		{"1:2:9+1", "(range 1 2 (+ 9 1))"},
+		{"sin(X(4))*cos(U(1)+2.5e-3)", "(* (call sin (index X 4)) (call cos (+ (index U 1) 2.5e-3)))"},
+		{"atan2(X(1),2i)", "(call atan2 (index X 1) 2i)"},
+		{"X(:,1:2)", "(index X : (range 1 2))"},
+		{"X", "X"},
+		{"[1,2;3,X(4)]", "[1 2; 3 (index X 4)]"},
+		{"[1;]", "[1]"},
+		{"[]", "[]"},
+		{"[X(7);(U(1)*sin(U(2)))/4-979/100]", "[(index X 7); (- (/ (paren (* (index U 1) (call sin (index U 2)))) 4) (/ 979 100))]"},
+	}
+	// Inputs formatted differently, with parentheses added for MATLAB.
+	reformatted := map[string]string{"[1;]": "[1]"}
+	for _, test := range tests {
+		x, err := parseString(t, test.input)
+		if err != nil {
+			t.Errorf("%q: %v", test.input, err)
+			continue
+		}
+		if got := sexpr(x); got != test.want {
+			t.Errorf("%q: got %s, want %s", test.input, got, test.want)
+		}
+		if x.Pos() != 0 || x.End() != len(test.input) {
+			t.Errorf("%q: got span [%d,%d), want whole input", test.input, x.Pos(), x.End())
+		}
+		want, ok := reformatted[test.input]
+		if !ok {
+			want = test.input
+		}
+		if got := Format(x); got != want {
+			t.Errorf("%q: formatted as %q, want %q", test.input, got, want)
+		}
+	}
+}
+
+func TestParseMatrixSpaces(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"[1 2 3]", "[1 2 3]"},
+		{"[1 -2]", "[1 (- 2)]"},
+		{"[1 - 2]", "[(- 1 2)]"},
+		{"[1-2]", "[(- 1 2)]"},
+		{"[1 +X(1); 3 4]", "[1 (+ (index X 1)); 3 4]"},
+		{"[ 1 , 2 ]", "[1 2]"},
+		{"[sin(1) (2) pi]", "[(call sin 1) (paren 2) pi]"},
+		{"[(1 -2) 3]", "[(paren (- 1 2)) 3]"},
+		{"[X(1 -2) [1 2]]", "[(index X (- 1 2)) [1 2]]"},
+		{"[1 -(2)]", "[1 (- (paren 2))]"},
+		{"[1 pi X(2) - U(1)]", "[1 pi (- (index X 2) (index U 1))]"},
+	}
+	for _, test := range tests {
+		x, err := parseString(t, test.input)
+		if err != nil {
+			t.Errorf("%q: %v", test.input, err)
+			continue
+		}
+		if got := sexpr(x); got != test.want {
+			t.Errorf("%q: got %s, want %s", test.input, got, test.want)
+		}
+	}
+}
+
+func TestFormatPrecedence(t *testing.T) {
+	// Trees built without Paren nodes get parentheses where needed.
+	one, two := &Number{Value: "1"}, &Number{Value: "2"}
+	tests := []struct {
+		x    Expr
+		want string
+	}{
+		{&Binary{X: &Binary{X: one, Op: '+', Y: two}, Op: '*', Y: two}, "(1+2)*2"},
+		{&Binary{X: one, Op: '-', Y: &Binary{X: one, Op: '-', Y: two}}, "1-(1-2)"},
+		{&Binary{X: &Binary{X: one, Op: '^', Y: two}, Op: '^', Y: two}, "1^2^2"},
+		{&Binary{X: one, Op: '^', Y: &Binary{X: one, Op: '^', Y: two}}, "1^(1^2)"},
+		{&Binary{X: one, Op: '^', Y: &Unary{Op: '-', X: two}}, "1^-2"},
+		{&Binary{X: one, Op: '^', Y: &Unary{Op: '-', X: &Binary{X: one, Op: '^', Y: two}}}, "1^(-1^2)"},
+		{&Binary{X: &Unary{Op: '-', X: one}, Op: '^', Y: two}, "(-1)^2"},
+		{&Unary{Op: '-', X: &Binary{X: one, Op: '^', Y: two}}, "-1^2"},
+		{&Range{Start: &Range{Start: one, Stop: two}, Stop: two}, "(1:2):2"},
+	}
+	for _, test := range tests {
+		if got := Format(test.x); got != test.want {
+			t.Errorf("got %s, want %s", got, test.want)
+		}
+	}
+}
+
+// TestFormatRoundTrip checks formatted powers parse back to the same tree.
+func TestFormatRoundTrip(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"2^3^2", "2^3^2"},
+		{"(2^3)^2", "(2^3)^2"},
+		{"2^-3^2", "2^-3^2"},
+		{"2^(-3^2)", "2^(-3^2)"},
+		{"X(1)^2^-1*3", "X(1)^2^-1*3"},
+	}
+	for _, test := range tests {
+		x, err := parseString(t, test.input)
+		if err != nil {
+			t.Fatalf("%q: %v", test.input, err)
+		}
+		got := Format(x)
+		if got != test.want {
+			t.Errorf("%q: formatted as %q, want %q", test.input, got, test.want)
+		}
+		y, err := parseString(t, got)
+		if err != nil {
+			t.Fatalf("%q: %v", got, err)
+		}
+		if Format(y) != got || noParens(sexpr(y)) != noParens(sexpr(x)) {
+			t.Errorf("%q: formatted %q parses as %s, want %s", test.input, got, sexpr(y), sexpr(x))
+		}
+	}
+}
+
+// noParens removes the paren nodes of a prefix expression returned by sexpr.
+func noParens(s string) string {
+	for {
+		i := strings.Index(s, "(paren ")
+		if i < 0 {
+			return s
+		}
+		depth := 0
+		for j := i; j < len(s); j++ {
+			if s[j] == '(' {
+				depth++
+			} else if s[j] == ')' {
+				depth--
+			}
+			if depth == 0 {
+				s = s[:i] + s[i+len("(paren "):j] + s[j+1:]
+				break
+			}
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		col   int
+		msg   string
+	}{
+		{"1+", 3, "expected expression, found end of input"},
+		{"1:2:3:4", 6, "range has too many colons"},
+		{"[1,2", 5, "expected ']' closing '['"},
+		{"X(1", 4, "expected ')' closing '('"},
+		{"2*foo", 3, "undefined identifier"},
+		{"(1)(2)", 4, "unexpected \"(\" after expression"},
+		{"X(:+1)", 4, "expected ',' or ')' after lone ':'"},
+	}
+	for _, test := range tests {
+		_, err := parseString(t, test.input)
+		var perr *Error
+		if !errors.As(err, &perr) {
+			t.Errorf("%q: expected *Error, got %v", test.input, err)
+			continue
+		}
+		if perr.Col != test.col || !strings.Contains(perr.Msg, test.msg) {
+			t.Errorf("%q: got error at column %d %q, want column %d %q", test.input, perr.Col, perr.Msg, test.col, test.msg)
+		}
+	}
+}
+
+func TestParseLexError(t *testing.T) {
+	_, err := parseString(t, "1+sin")
+	const want = "1:6: I looked for a function opening meta and couldn't find one"
+	if err == nil || err.Error() != want {
+		t.Errorf("got error %q, want %q", err, want)
+	}
+}
+
+func TestParseUndefined(t *testing.T) {
+	l, err := pike.NewLexer("test.m", "f(a)+b", pike.Options{AllowUndefined: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	x, err := Parse(l.Items())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := sexpr(x); got != "(+ (call f a) b)" {
+		t.Errorf("got %s", got)
+	}
+	if call := x.(*Binary).X.(*Call); call.Func.Kind != IdentUnknown {
+		t.Errorf("got identifier kind %d, want IdentUnknown", call.Func.Kind)
+	}
+}
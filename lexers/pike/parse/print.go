@@ -0,0 +1,114 @@
+package parse
+
+// precAtom is the precedence of expressions that never need parentheses.
+const precAtom = precPow + 1
+
+// Format returns x in compact MATLAB syntax. Parentheses of Paren nodes are kept
+// and parentheses are added where operator precedence requires them.
+func Format(x Expr) string {
+	return string(AppendFormat(nil, x))
+}
+
+// AppendFormat appends x formatted as by [Format] to dst and returns the result.
+func AppendFormat(dst []byte, x Expr) []byte {
+	switch x := x.(type) {
+	case *Number:
+		return append(dst, x.Value...)
+	case *Ident:
+		return append(dst, x.Name...)
+	case *Colon:
+		return append(dst, ':')
+	case *Unary:
+		dst = append(dst, x.Op)
+		return appendOperand(dst, x.X, precUnary, false)
+	case *Binary:
+		// All binary operators are left associative, so a^(b^c) keeps its parentheses.
+		prec := opPrec(x.Op)
+		dst = appendOperand(dst, x.X, prec, false)
+		dst = append(dst, x.Op)
+		if u, ok := x.Y.(*Unary); ok && x.Op == '^' && exprPrec(u.X) != precPow {
+			return AppendFormat(dst, x.Y) // Signs may follow ^ as in 2^-1, applying to the exponent only.
+		}
+		return appendOperand(dst, x.Y, prec, true)
+	case *Range:
+		dst = appendOperand(dst, x.Start, precRange, true)
+		if x.Step != nil {
+			dst = append(dst, ':')
+			dst = appendOperand(dst, x.Step, precRange, true)
+		}
+		dst = append(dst, ':')
+		return appendOperand(dst, x.Stop, precRange, true)
+	case *Call:
+		dst = append(dst, x.Func.Name...)
+		return appendArgs(dst, x.Args)
+	case *Index:
+		dst = append(dst, x.X.Name...)
+		return appendArgs(dst, x.Args)
+	case *Paren:
+		dst = append(dst, '(')
+		dst = AppendFormat(dst, x.X)
+		return append(dst, ')')
+	case *Matrix:
+		dst = append(dst, '[')
+		for i, row := range x.Rows {
+			if i > 0 {
+				dst = append(dst, ';')
+			}
+			for j, elem := range row {
+				if j > 0 {
+					dst = append(dst, ',')
+				}
+				dst = AppendFormat(dst, elem)
+			}
+		}
+		return append(dst, ']')
+	}
+	return dst
+}
+
+// appendOperand appends operand x of an operator of precedence prec,
+// parenthesized if it binds weaker, or as strongly and strict is set.
+func appendOperand(dst []byte, x Expr, prec int, strict bool) []byte {
+	xprec := exprPrec(x)
+	if xprec < prec || strict && xprec == prec {
+		dst = append(dst, '(')
+		dst = AppendFormat(dst, x)
+		return append(dst, ')')
+	}
+	return AppendFormat(dst, x)
+}
+
+func appendArgs(dst []byte, args []Expr) []byte {
+	dst = append(dst, '(')
+	for i, arg := range args {
+		if i > 0 {
+			dst = append(dst, ',')
+		}
+		dst = AppendFormat(dst, arg)
+	}
+	return append(dst, ')')
+}
+
+func exprPrec(x Expr) int {
+	switch x := x.(type) {
+	case *Unary:
+		return precUnary
+	case *Binary:
+		return opPrec(x.Op)
+	case *Range:
+		return precRange
+	}
+	return precAtom
+}
+
+func opPrec(op byte) int {
+	switch op {
+	case '+', '-':
+		return precAdd
+	case '*', '/':
+		return precMul
+	case '^':
+		return precPow
+	}
+	return precAtom
+}
@@ -1,32 +1,23 @@
 package pike
 
-import (
-	"strings"
-)
-
 const idRuneSet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789_"
 
-type stateFn func(*lexer) stateFn
-
-var funcNames map[string]struct{}
+type stateFn func(*Lexer) stateFn
 
-// This is the initial state and base state
-func lexStart(l *lexer) stateFn {
-	var eofFound bool
-	for !eofFound {
-		if strings.HasPrefix(l.input[l.pos:], leftMatMeta) {
-			l.emitJunk() // Emit whatever came before matrix if anything at all
+// This is the initial state and base state.
+// Whitespace is skipped so that items start at their first significant rune.
+func lexStart(l *Lexer) stateFn {
+	for {
+		switch r := l.peek(); {
+		case r == l.brackets.MatrixOpen:
 			return lexLeftMatMeta
-		} else if strings.HasPrefix(l.input[l.pos:], rightMatMeta) {
-			l.emitJunk()
+		case r == l.brackets.MatrixClose:
 			return lexRightMatMeta
-		} else if strings.HasPrefix(l.input[l.pos:], ")") {
+		case r == l.brackets.GroupClose:
 			return lexClosingMeta
-		}
-		switch r := l.peek(); {
 		case r == eof:
 			return lexEOF
-		case isNumeric(r):
+		case isNumeric(r) || r == '.' && isNumeric(l.peekN(1)):
 			return lexNumber
 		case isASCIIAlpha(r):
 			return lexAlpha
@@ -34,38 +25,61 @@ func lexStart(l *lexer) stateFn {
 			return lexOperator
 		case isSeparator(r):
 			return lexSeparator
-		case r == '(':
+		case r == l.brackets.GroupOpen:
 			return lexLeftPemdas
+		case isSpace(r):
+			l.next()
+			l.ignore()
+			continue
 		}
 		l.next()
+		return l.errorf("I found an unexpected character %q", l.input[l.start:l.pos])
 	}
-	panic("unreachable") // end of function on EOF. See lexEOF
 }
 
-func lexAlpha(l *lexer) stateFn {
+func lexAlpha(l *Lexer) stateFn {
 	l.acceptRun(idRuneSet)
-	idType := l.getIDType(l.input[l.start:l.pos])
-	switch idType {
-	case idUndefined:
-		l.errorf("I found an undefined identifier '%s'", l.input[l.start:l.pos])
+	name := l.input[l.start:l.pos]
+	var typ ItemType
+	switch l.getIDType(name) {
 	case idFunc:
+		typ = ItemFunc
+	case idVar:
+		typ = ItemVar
+	case idUndefined:
+		typ = ItemError
+		if l.undefined != nil {
+			typ = l.undefined(name, l.peek() == l.brackets.GroupOpen)
+		}
+	}
+	switch typ {
+	case ItemError:
+		return l.errorf("I found an undefined identifier '%s'", name)
+	case ItemIdentifier:
+		l.emit(ItemIdentifier)
+		return lexStart
+	case ItemFunc:
+		if l.identifiers[name].nullary && l.peek() != l.brackets.GroupOpen {
+			l.emit(ItemIdentifier) // Constant such as pi, called without arguments.
+			return lexStart
+		}
 		l.emit(ItemFunc)
 		return lexLeftFuncMeta
-	case idVar:
+	case ItemVar:
 		l.emit(ItemVar)
-		l.accept(leftIdxMeta)
-		if l.pos-l.start == len(leftIdxMeta) {
-			l.metaWrap(ItemLeftIdxMeta)
+		if l.peek() == l.brackets.GroupOpen {
+			l.next()
+			l.metaWrap(ItemLeftIdxMeta) // Openers always succeed.
 			l.emit(ItemLeftIdxMeta)
 		}
 		return lexStart
 	}
-	return l.errorf("Unhandled id type for identifier %s", l.input[l.start:l.pos])
+	return l.errorf("Unhandled item type %s for identifier %s", typ, name)
 }
 
-func lexLeftFuncMeta(l *lexer) stateFn {
-	if strings.HasPrefix(l.input[l.pos:], leftFuncMeta) {
-		l.pos += len(leftFuncMeta)
+func lexLeftFuncMeta(l *Lexer) stateFn {
+	if l.peek() == l.brackets.GroupOpen {
+		l.next()
 		l.metaWrap(ItemLeftFuncMeta)
 		l.emit(ItemLeftFuncMeta)
 		return lexStart
@@ -73,7 +87,7 @@ func lexLeftFuncMeta(l *lexer) stateFn {
 	return l.errorf("I looked for a function opening meta and couldn't find one")
 }
 
-func lexNumber(l *lexer) stateFn {
+func lexNumber(l *Lexer) stateFn {
 	// Lex number, decimal, float, imaginary
 	l.accept("+-")
 	digits := "0123456789" // only decimal
@@ -97,18 +111,22 @@ func lexNumber(l *lexer) stateFn {
 	return lexStart
 }
 
-func lexLeftMatMeta(l *lexer) stateFn {
-	l.pos += len(leftMatMeta)
+func lexLeftMatMeta(l *Lexer) stateFn {
+	l.next()
+	l.metaWrap(ItemLeftMatMeta)
 	l.emit(ItemLeftMatMeta)
 	return lexStart // Now inside [ ].
 }
-func lexRightMatMeta(l *lexer) stateFn {
-	l.pos += len(rightMatMeta)
+func lexRightMatMeta(l *Lexer) stateFn {
+	l.next()
+	if !l.metaWrap(ItemRightMatMeta) {
+		return nil
+	}
 	l.emit(ItemRightMatMeta)
 	return lexStart // exiting [ ].
 }
 
-func lexOperator(l *lexer) stateFn {
+func lexOperator(l *Lexer) stateFn {
 	if l.accept("+-*/^") {
 		l.emit(ItemOperator)
 	} else if l.accept(":") {
@@ -119,7 +137,7 @@ func lexOperator(l *lexer) stateFn {
 	return lexStart
 }
 
-func lexSeparator(l *lexer) stateFn {
+func lexSeparator(l *Lexer) stateFn {
 	if l.accept(",") {
 		l.emit(ItemCommaSep)
 	} else if l.accept(";") {
@@ -130,35 +148,38 @@ func lexSeparator(l *lexer) stateFn {
 	return lexStart
 }
 
-func lexClosingMeta(l *lexer) stateFn {
-	l.pos += len(")")
+func lexClosingMeta(l *Lexer) stateFn {
+	l.next()
+	var closer ItemType
 	switch currentMeta := l.metaCurrent(); {
 	case currentMeta == ItemLeftIdxMeta:
-		l.metaWrap(ItemRightIdxMeta)
-		l.emit(ItemRightIdxMeta)
+		closer = ItemRightIdxMeta
 	case currentMeta == ItemLeftFuncMeta:
-		l.metaWrap(ItemRightFuncMeta)
-		l.emit(ItemRightFuncMeta)
+		closer = ItemRightFuncMeta
 	case currentMeta == ItemLeftPemdas:
-		l.metaWrap(ItemRightPemdas)
-		l.emit(ItemRightPemdas)
+		closer = ItemRightPemdas
+	default:
+		return l.metaMismatch(ItemRightPemdas) // No opener or '[' at top.
 	}
+	l.metaWrap(closer) // Matches opener at top.
+	l.emit(closer)
 	return lexStart
 }
 
-func lexLeftPemdas(l *lexer) stateFn {
-	if l.accept("(") {
-		l.emit(ItemLeftPemdas)
+func lexLeftPemdas(l *Lexer) stateFn {
+	if l.peek() == l.brackets.GroupOpen {
+		l.next()
 		l.metaWrap(ItemLeftPemdas)
+		l.emit(ItemLeftPemdas)
 	} else {
 		return l.errorf("I was expecting to find left group (pemdas)")
 	}
 	return lexStart
 }
 
-func lexEOF(l *lexer) stateFn {
-	if l.pos > l.start {
-		l.emit(ItemText)
+func lexEOF(l *Lexer) stateFn {
+	if l.metaStack.Len() > 0 {
+		return l.metaMismatch(ItemNil)
 	}
 	l.emit(ItemEOF)
 	return nil
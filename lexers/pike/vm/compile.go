@@ -0,0 +1,321 @@
+package vm
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/soypat/lexer/lexers/pike/parse"
+)
+
+// Error is a compilation error positioned in the input of the expression.
+type Error struct {
+	Pos, End int // Byte offsets of the expression that failed to compile.
+	Msg      string
+}
+
+// Error returns the error formatted as "offset: message".
+func (e *Error) Error() string {
+	return strconv.Itoa(e.Pos) + ": " + e.Msg
+}
+
+func errorf(x parse.Expr, format string, args ...any) error {
+	return &Error{Pos: x.Pos(), End: x.End(), Msg: fmt.Sprintf(format, args...)}
+}
+
+// node is a node of the expression graph in which equal subexpressions
+// are a single node, so that they are computed once.
+type node struct {
+	op   opcode
+	arg  uint32
+	x, y int32   // Operand nodes, or -1.
+	val  float64 // Value of opConst nodes.
+	uses int     // Number of nodes and outputs using the value.
+	slot int32   // Constant or register index once emitted, or -1.
+}
+
+// key identifies nodes computing the same value.
+type key struct {
+	op   opcode
+	arg  uint32
+	x, y int32
+	bits uint64
+}
+
+type compiler struct {
+	xname, uname string
+	nodes        []node
+	ids          map[key]int32
+	p            *Program
+	depth, nregs int
+}
+
+// Compile compiles x to a program evaluating it with the variables named
+// xname and uname bound to the X and U arguments of [Program.Eval].
+// Variables must be indexed with constant subscripts, as in X(4), and
+// x must be a scalar or a matrix literal of scalars such as [X(7);X(8)].
+func Compile(x parse.Expr, xname, uname string) (*Program, error) {
+	c := compiler{
+		xname: xname,
+		uname: uname,
+		ids:   make(map[key]int32),
+		p:     &Program{xname: xname, uname: uname},
+	}
+	elems, err := c.outputs(x)
+	if err != nil {
+		return nil, err
+	}
+	outs := make([]int32, len(elems))
+	for i, elem := range elems {
+		outs[i], err = c.expr(elem)
+		if err != nil {
+			return nil, err
+		}
+		c.nodes[outs[i]].uses++
+	}
+	for i, id := range outs {
+		c.emit(id)
+		c.push(opOut, uint32(i))
+	}
+	c.p.mem = make([]float64, c.p.nstack+c.nregs)
+	return c.p, nil
+}
+
+// outputs returns the elements of x in column-major order and sets the dimensions of the program.
+func (c *compiler) outputs(x parse.Expr) ([]parse.Expr, error) {
+	m, ok := x.(*parse.Matrix)
+	if !ok {
+		c.p.rows, c.p.cols = 1, 1
+		return []parse.Expr{x}, nil
+	}
+	if len(m.Rows) == 0 {
+		return nil, nil
+	}
+	rows, cols := len(m.Rows), len(m.Rows[0])
+	elems := make([]parse.Expr, rows*cols)
+	for i, row := range m.Rows {
+		if len(row) != cols {
+			return nil, errorf(row[0], "row %d has %d elements, want %d", i+1, len(row), cols)
+		}
+		for j, elem := range row {
+			elems[j*rows+i] = elem
+		}
+	}
+	c.p.rows, c.p.cols = rows, cols
+	return elems, nil
+}
+
+func (c *compiler) expr(x parse.Expr) (int32, error) {
+	switch x := x.(type) {
+	case *parse.Number:
+		if x.Imag {
+			return -1, errorf(x, "complex numbers not supported")
+		}
+		f, err := strconv.ParseFloat(x.Value, 64)
+		if err != nil {
+			return -1, errorf(x, "invalid number %q", x.Value)
+		}
+		return c.constant(f), nil
+	case *parse.Paren:
+		return c.expr(x.X)
+	case *parse.Unary:
+		id, err := c.expr(x.X)
+		if err != nil || x.Op == '+' {
+			return id, err
+		}
+		return c.node(opNeg, 0, id, -1), nil
+	case *parse.Binary:
+		a, err := c.expr(x.X)
+		if err != nil {
+			return -1, err
+		}
+		b, err := c.expr(x.Y)
+		if err != nil {
+			return -1, err
+		}
+		var op opcode
+		switch x.Op {
+		case '+':
+			op = opAdd
+		case '-':
+			op = opSub
+		case '*':
+			op = opMul
+		case '/':
+			op = opDiv
+		case '^':
+			if n := c.nodes[b]; n.op == opConst && n.val == 2 {
+				return c.node(opSquare, 0, a, -1), nil
+			}
+			op = opPow
+		default:
+			return -1, errorf(x, "operator %c not supported", x.Op)
+		}
+		return c.node(op, 0, a, b), nil
+	case *parse.Index:
+		return c.index(x, x.X, x.Args)
+	case *parse.Call:
+		if c.isVar(x.Func) {
+			return c.index(x, x.Func, x.Args) // Undefined identifier at lexing.
+		}
+		return c.call(x, x.Func, x.Args)
+	case *parse.Ident:
+		if c.isVar(x) {
+			return -1, errorf(x, "vector %s must be indexed", x.Name)
+		}
+		return c.call(x, x, nil)
+	case *parse.Matrix:
+		return -1, errorf(x, "nested matrices not supported")
+	case *parse.Range, *parse.Colon:
+		return -1, errorf(x, "ranges not supported")
+	}
+	return -1, errorf(x, "unsupported expression %T", x)
+}
+
+func (c *compiler) isVar(id *parse.Ident) bool {
+	return id.Kind != parse.IdentFunc && (id.Name == c.xname || id.Name == c.uname)
+}
+
+// index compiles the element of variable v selected by a constant 1-based subscript.
+func (c *compiler) index(x parse.Expr, v *parse.Ident, args []parse.Expr) (int32, error) {
+	if !c.isVar(v) {
+		return -1, errorf(v, "undefined variable %s", v.Name)
+	} else if len(args) != 1 {
+		return -1, errorf(x, "index of %s must have 1 subscript, got %d", v.Name, len(args))
+	}
+	id, err := c.expr(args[0])
+	if err != nil {
+		return -1, err
+	}
+	n := c.nodes[id]
+	if n.op != opConst {
+		return -1, errorf(args[0], "index of %s must be constant", v.Name)
+	} else if n.val < 1 || n.val != math.Trunc(n.val) || n.val > math.MaxInt32 {
+		return -1, errorf(args[0], "index must be a positive integer, got %v", n.val)
+	}
+	k := int(n.val)
+	if v.Name == c.xname {
+		c.p.nx = max(c.p.nx, k)
+		return c.node(opX, uint32(k-1), -1, -1), nil
+	}
+	c.p.nu = max(c.p.nu, k)
+	return c.node(opU, uint32(k-1), -1, -1), nil
+}
+
+func (c *compiler) call(x parse.Expr, fn *parse.Ident, args []parse.Expr) (int32, error) {
+	if v, ok := constFuncs[fn.Name]; ok && len(args) == 0 {
+		return c.constant(v), nil
+	}
+	op, arg, ok := lookupFunc(fn.Name, len(args))
+	if !ok {
+		return -1, errorf(x, "function %s of %d arguments not supported", fn.Name, len(args))
+	}
+	operands := [2]int32{-1, -1}
+	for i, a := range args {
+		id, err := c.expr(a)
+		if err != nil {
+			return -1, err
+		}
+		operands[i] = id
+	}
+	return c.node(op, arg, operands[0], operands[1]), nil
+}
+
+func (c *compiler) constant(f float64) int32 {
+	return c.add(node{op: opConst, x: -1, y: -1, val: f})
+}
+
+// node returns the node computing op of operands x and y, folding constants.
+func (c *compiler) node(op opcode, arg uint32, x, y int32) int32 {
+	if op != opX && op != opU && c.nodes[x].op == opConst && (y < 0 || c.nodes[y].op == opConst) {
+		var b float64
+		if y >= 0 {
+			b = c.nodes[y].val
+		}
+		return c.constant(fold(op, arg, c.nodes[x].val, b))
+	}
+	if (op == opAdd || op == opMul) && x > y {
+		x, y = y, x // Commutative operations are exact in either order.
+	}
+	return c.add(node{op: op, arg: arg, x: x, y: y})
+}
+
+func (c *compiler) add(n node) int32 {
+	k := key{op: n.op, arg: n.arg, x: n.x, y: n.y, bits: math.Float64bits(n.val)}
+	if id, ok := c.ids[k]; ok {
+		return id
+	}
+	id := int32(len(c.nodes))
+	n.slot = -1
+	c.nodes = append(c.nodes, n)
+	c.ids[k] = id
+	for _, operand := range [2]int32{n.x, n.y} {
+		if operand >= 0 {
+			c.nodes[operand].uses++
+		}
+	}
+	return id
+}
+
+// fold computes op on constants as the VM would.
+func fold(op opcode, arg uint32, x, y float64) float64 {
+	switch op {
+	case opNeg:
+		return -x
+	case opSquare:
+		return x * x
+	case opAdd:
+		return x + y
+	case opSub:
+		return x - y
+	case opMul:
+		return x * y
+	case opDiv:
+		return x / y
+	case opPow:
+		return math.Pow(x, y)
+	case opCall1:
+		return funcs1[arg].f(x)
+	case opCall2:
+		return funcs2[arg].f(x, y)
+	}
+	panic("unreachable")
+}
+
+// emit emits the code computing node id. Values of nodes with more than one
+// use are stored in a register when first computed and loaded thereafter.
+func (c *compiler) emit(id int32) {
+	n := &c.nodes[id]
+	switch {
+	case n.op == opConst:
+		if n.slot < 0 {
+			n.slot = int32(len(c.p.consts))
+			c.p.consts = append(c.p.consts, n.val)
+		}
+		c.push(opConst, uint32(n.slot))
+		return
+	case n.op == opX || n.op == opU:
+		c.push(n.op, n.arg)
+		return
+	case n.slot >= 0:
+		c.push(opLoad, uint32(n.slot))
+		return
+	}
+	op, arg, x, y := n.op, n.arg, n.x, n.y
+	c.emit(x)
+	if y >= 0 {
+		c.emit(y)
+	}
+	c.push(op, arg)
+	if n = &c.nodes[id]; n.uses > 1 {
+		n.slot = int32(c.nregs)
+		c.nregs++
+		c.push(opStore, uint32(n.slot))
+	}
+}
+
+func (c *compiler) push(op opcode, arg uint32) {
+	c.p.code = append(c.p.code, instr{op: op, arg: arg})
+	c.depth += op.stackEffect()
+	c.p.nstack = max(c.p.nstack, c.depth)
+}
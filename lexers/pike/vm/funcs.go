@@ -0,0 +1,126 @@
+package vm
+
+import "math"
+
+type func1 struct {
+	name string
+	f    func(float64) float64
+}
+
+type func2 struct {
+	name string
+	f    func(x, y float64) float64
+}
+
+// funcs1 are the built-in functions of pike of one real argument.
+var funcs1 = []func1{
+	{"sin", math.Sin},
+	{"cos", math.Cos},
+	{"tan", math.Tan},
+	{"asin", math.Asin},
+	{"acos", math.Acos},
+	{"atan", math.Atan},
+	{"sec", func(x float64) float64 { return 1 / math.Cos(x) }},
+	{"csc", func(x float64) float64 { return 1 / math.Sin(x) }},
+	{"cot", func(x float64) float64 { return math.Cos(x) / math.Sin(x) }},
+	{"sinh", math.Sinh},
+	{"cosh", math.Cosh},
+	{"tanh", math.Tanh},
+	{"asinh", math.Asinh},
+	{"acosh", math.Acosh},
+	{"atanh", math.Atanh},
+	{"exp", math.Exp},
+	{"expm1", math.Expm1},
+	{"log", math.Log},
+	{"log1p", math.Log1p},
+	{"log2", math.Log2},
+	{"log10", math.Log10},
+	{"sqrt", math.Sqrt},
+	{"abs", math.Abs},
+	{"sign", sign},
+	{"floor", math.Floor},
+	{"ceil", math.Ceil},
+	{"fix", math.Trunc},
+	{"round", math.Round},
+	{"real", func(x float64) float64 { return x }},
+	{"imag", func(x float64) float64 { return 0 }},
+	{"conj", func(x float64) float64 { return x }},
+	{"angle", func(x float64) float64 { return math.Atan2(0, x) }},
+	{"gamma", math.Gamma},
+	{"erf", math.Erf},
+	{"erfc", math.Erfc},
+}
+
+// funcs2 are the built-in functions of pike of two real arguments.
+var funcs2 = []func2{
+	{"atan2", math.Atan2},
+	{"hypot", math.Hypot},
+	{"mod", func(x, y float64) float64 { return remainder(x, y, math.Floor) }},
+	{"rem", func(x, y float64) float64 { return remainder(x, y, math.Trunc) }},
+	{"nthroot", nthroot},
+	{"power", math.Pow},
+	{"round", func(x, digits float64) float64 {
+		scale := math.Pow(10, digits)
+		return math.Round(x*scale) / scale
+	}},
+}
+
+// constFuncs are the built-in functions of pike evaluating to a scalar without arguments.
+var constFuncs = map[string]float64{
+	"pi":    math.Pi,
+	"eps":   0x1p-52,
+	"zeros": 0,
+	"ones":  1,
+	"eye":   1,
+}
+
+// lookupFunc returns the opcode and function index of name called with nargs arguments.
+func lookupFunc(name string, nargs int) (opcode, uint32, bool) {
+	switch nargs {
+	case 1:
+		for i, f := range funcs1 {
+			if f.name == name {
+				return opCall1, uint32(i), true
+			}
+		}
+	case 2:
+		for i, f := range funcs2 {
+			if f.name == name {
+				return opCall2, uint32(i), true
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+func sign(x float64) float64 {
+	switch {
+	case x > 0:
+		return 1
+	case x < 0:
+		return -1
+	case x == 0:
+		return 0
+	}
+	return x // NaN.
+}
+
+// remainder returns x - round(x/y)*y, which is x if y is zero as in MATLAB.
+func remainder(x, y float64, round func(float64) float64) float64 {
+	if y == 0 {
+		return x
+	}
+	return x - round(x/y)*y
+}
+
+// nthroot returns the real nth root of x, negative for negative x and odd n.
+func nthroot(x, n float64) float64 {
+	if x < 0 && math.Mod(n, 2) != 1 && math.Mod(n, 2) != -1 {
+		return math.NaN()
+	}
+	y := math.Copysign(math.Pow(math.Abs(x), 1/n), x)
+	if y != 0 && !math.IsInf(y, 0) && !math.IsNaN(y) {
+		y -= (math.Pow(y, n) - x) / (n * math.Pow(y, n-1)) // Newton step corrects rounding of 1/n.
+	}
+	return y
+}
@@ -0,0 +1,233 @@
+package vm
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/format"
+	"go/token"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// GenConfig configures the generation of Go source for a compiled expression.
+type GenConfig struct {
+	Package string // Package of the generated file.
+	Command string // Command recorded in the generated file header.
+	Func    string // Name of the generated function. Defaults to "F".
+}
+
+// Go operator precedences of generated expressions.
+const (
+	goPrecAdd = iota + 4
+	goPrecMul
+	goPrecUnary
+	goPrecAtom
+)
+
+// goExpr is a Go expression generated for a value on the stack of a program.
+type goExpr struct {
+	src    string
+	prec   int
+	simple bool // Variable, literal or index expression, cheap to repeat.
+}
+
+// goLiterals are the function literals of generated code for functions of one
+// argument not in package math, and goLiterals2 for functions of two arguments.
+var (
+	goLiterals = map[string]string{
+		"sec":   "func(x float64) float64 { return 1 / math.Cos(x) }",
+		"csc":   "func(x float64) float64 { return 1 / math.Sin(x) }",
+		"cot":   "func(x float64) float64 { return math.Cos(x) / math.Sin(x) }",
+		"sign":  "func(x float64) float64 {\nswitch {\ncase x > 0:\nreturn 1\ncase x < 0:\nreturn -1\ncase x == 0:\nreturn 0\n}\nreturn x\n}",
+		"real":  "func(x float64) float64 { return x }",
+		"imag":  "func(x float64) float64 { return 0 }",
+		"conj":  "func(x float64) float64 { return x }",
+		"angle": "func(x float64) float64 { return math.Atan2(0, x) }",
+	}
+	goLiterals2 = map[string]string{
+		"mod":     "func(x, y float64) float64 {\nif y == 0 {\nreturn x\n}\nreturn x - math.Floor(x/y)*y\n}",
+		"rem":     "func(x, y float64) float64 {\nif y == 0 {\nreturn x\n}\nreturn x - math.Trunc(x/y)*y\n}",
+		"nthroot": "func(x, n float64) float64 {\nif x < 0 && math.Mod(n, 2) != 1 && math.Mod(n, 2) != -1 {\nreturn math.NaN()\n}\ny := math.Copysign(math.Pow(math.Abs(x), 1/n), x)\nif y != 0 && !math.IsInf(y, 0) && !math.IsNaN(y) {\ny -= (math.Pow(y, n) - x) / (n * math.Pow(y, n-1))\n}\nreturn y\n}",
+		"round":   "func(x, digits float64) float64 {\nscale := math.Pow(10, digits)\nreturn math.Round(x*scale) / scale\n}",
+	}
+	// goMath are the names of functions of package math which differ from pike's.
+	goMath = map[string]string{"fix": "Trunc", "power": "Pow"}
+)
+
+// Generate returns formatted Go source declaring a function that performs the
+// operations of p in the same order as [Program.Eval], with the variables of
+// [Compile] as parameters, i.e:
+//
+//	func F(X, U, out []float64)
+//
+// Indices of the variables are translated to 0-based indices, values stored in
+// registers of p are hoisted to local variables, squares are computed by
+// multiplication and other powers by math.Pow. Functions not in package math
+// are declared as function literals in the generated function.
+// The function and variable names must be Go identifiers other than the names
+// of generated code: out, math, float64, the locals t0, t1... and the function literals.
+func (cfg *GenConfig) Generate(p *Program) ([]byte, error) {
+	fn := cfg.Func
+	if fn == "" {
+		fn = "F"
+	}
+	if cfg.Package == "" {
+		return nil, errors.New("package required")
+	} else if !token.IsIdentifier(cfg.Package) {
+		return nil, fmt.Errorf("package name %q is not a Go identifier", cfg.Package)
+	} else if p.xname == p.uname {
+		return nil, fmt.Errorf("variables must have distinct names, got %s twice", p.xname)
+	}
+	for _, name := range []string{fn, p.xname, p.uname} {
+		if err := checkName(name); err != nil {
+			return nil, err
+		}
+	}
+	if fn == "init" {
+		return nil, errors.New("function name init is reserved")
+	}
+	var (
+		body    bytes.Buffer
+		decls   []string // Declarations of function literals.
+		stack   []goExpr
+		regs    = make([]string, len(p.mem)-p.nstack)
+		nlocals int
+	)
+	local := func(e goExpr) goExpr {
+		name := "t" + strconv.Itoa(nlocals)
+		nlocals++
+		fmt.Fprintf(&body, "\t%s := %s\n", name, e.src)
+		return goExpr{src: name, prec: goPrecAtom, simple: true}
+	}
+	function := func(name string, literals map[string]string) string {
+		src, ok := literals[name]
+		if !ok {
+			if m, ok := goMath[name]; ok {
+				return "math." + m
+			}
+			return "math." + strings.ToUpper(name[:1]) + name[1:]
+		}
+		decl := name + " := " + src
+		for _, d := range decls {
+			if d == decl {
+				return name
+			}
+		}
+		decls = append(decls, decl)
+		return name
+	}
+	for _, in := range p.code {
+		var top *goExpr
+		if len(stack) > 0 {
+			top = &stack[len(stack)-1]
+		}
+		switch in.op {
+		case opConst:
+			stack = append(stack, goFloat(p.consts[in.arg]))
+		case opX:
+			stack = append(stack, goExpr{src: fmt.Sprintf("%s[%d]", p.xname, in.arg), prec: goPrecAtom, simple: true})
+		case opU:
+			stack = append(stack, goExpr{src: fmt.Sprintf("%s[%d]", p.uname, in.arg), prec: goPrecAtom, simple: true})
+		case opLoad:
+			stack = append(stack, goExpr{src: regs[in.arg], prec: goPrecAtom, simple: true})
+		case opStore:
+			*top = local(*top)
+			regs[in.arg] = top.src
+		case opOut:
+			fmt.Fprintf(&body, "\tout[%d] = %s\n", in.arg, top.src)
+			stack = stack[:len(stack)-1]
+		case opNeg:
+			*top = goExpr{src: "-" + goParen(*top, goPrecAtom), prec: goPrecUnary}
+		case opSquare:
+			if !top.simple {
+				*top = local(*top)
+			}
+			*top = goExpr{src: top.src + " * " + top.src, prec: goPrecMul}
+		case opAdd, opSub, opMul, opDiv:
+			x, y := stack[len(stack)-2], *top
+			prec := goPrecAdd
+			if in.op == opMul || in.op == opDiv {
+				prec = goPrecMul
+			}
+			// Parenthesize y of equal precedence to keep the order of floating point operations.
+			src := goParen(x, prec) + " " + "+-*/"[in.op-opAdd:in.op-opAdd+1] + " " + goParen(y, prec+1)
+			stack = append(stack[:len(stack)-2], goExpr{src: src, prec: prec})
+		case opPow:
+			x, y := stack[len(stack)-2], *top
+			stack = append(stack[:len(stack)-2], goExpr{src: "math.Pow(" + x.src + ", " + y.src + ")", prec: goPrecAtom})
+		case opCall1:
+			*top = goExpr{src: function(funcs1[in.arg].name, goLiterals) + "(" + top.src + ")", prec: goPrecAtom}
+		case opCall2:
+			x, y := stack[len(stack)-2], *top
+			f := function(funcs2[in.arg].name, goLiterals2)
+			stack = append(stack[:len(stack)-2], goExpr{src: f + "(" + x.src + ", " + y.src + ")", prec: goPrecAtom})
+		}
+	}
+
+	var b bytes.Buffer
+	if cfg.Command != "" {
+		fmt.Fprintf(&b, "// Code generated by %q; DO NOT EDIT.\n\n", cfg.Command)
+	} else {
+		b.WriteString("// Code generated by pike/vm; DO NOT EDIT.\n\n")
+	}
+	fmt.Fprintf(&b, "package %s\n\n", cfg.Package)
+	if strings.Contains(body.String(), "math.") || strings.Contains(strings.Join(decls, ""), "math.") {
+		b.WriteString("import \"math\"\n\n")
+	}
+	fmt.Fprintf(&b, "// %s stores the elements of the %dx%d value of the expression in out in column-major order.\n", fn, p.rows, p.cols)
+	fmt.Fprintf(&b, "func %s(%s, %s, out []float64) {\n", fn, p.xname, p.uname)
+	for _, bound := range []struct {
+		name string
+		n    int
+	}{{p.xname, p.nx}, {p.uname, p.nu}, {"out", p.NumOut()}} {
+		if bound.n > 0 {
+			fmt.Fprintf(&b, "\t_ = %s[%d] // Bounds check hint.\n", bound.name, bound.n-1)
+		}
+	}
+	for _, decl := range decls {
+		b.WriteString("\t" + decl + "\n")
+	}
+	b.Write(body.Bytes())
+	b.WriteString("}\n")
+	return format.Source(b.Bytes())
+}
+
+// checkName returns an error if name is not a Go identifier or collides
+// with a name used by generated code.
+func checkName(name string) error {
+	if !token.IsIdentifier(name) || name == "_" {
+		return fmt.Errorf("name %q is not a Go identifier", name)
+	}
+	_, literal := goLiterals[name]
+	_, literal2 := goLiterals2[name]
+	local := len(name) > 1 && name[0] == 't' && strings.Trim(name[1:], "0123456789") == ""
+	if literal || literal2 || local || name == "out" || name == "math" || name == "float64" {
+		return fmt.Errorf("name %q collides with a name of generated code", name)
+	}
+	return nil
+}
+
+// goFloat returns the Go literal of f.
+func goFloat(f float64) goExpr {
+	switch {
+	case math.IsNaN(f):
+		return goExpr{src: "math.NaN()", prec: goPrecAtom}
+	case math.IsInf(f, 0):
+		return goExpr{src: fmt.Sprintf("math.Inf(%d)", int(math.Copysign(1, f))), prec: goPrecAtom}
+	case f == 0 && math.Signbit(f):
+		return goExpr{src: "math.Copysign(0, -1)", prec: goPrecAtom} // Constant -0 is 0 in Go.
+	case f < 0:
+		return goExpr{src: strconv.FormatFloat(f, 'g', -1, 64), prec: goPrecUnary, simple: true}
+	}
+	return goExpr{src: strconv.FormatFloat(f, 'g', -1, 64), prec: goPrecAtom, simple: true}
+}
+
+// goParen returns the source of e, parenthesized if it binds weaker than prec.
+func goParen(e goExpr, prec int) string {
+	if e.prec < prec {
+		return "(" + e.src + ")"
+	}
+	return e.src
+}
@@ -0,0 +1,198 @@
+// Package vm compiles MATLAB expressions parsed by [parse.Parse] to a compact
+// stack-based bytecode and evaluates it without allocating, for expressions
+// such as the state derivative of a model evaluated in a simulation loop:
+//
+//	x, err := parse.Parse(lexer.Items())
+//	...
+//	prog, err := vm.Compile(x, "X", "U")
+//	...
+//	out := make([]float64, prog.NumOut())
+//	for ... {
+//		err = prog.Eval(X, U, out)
+//		...
+//	}
+//
+// Repeated subexpressions such as cos(X(4)) are computed once per evaluation
+// and constant subexpressions such as 979/100 once at compilation.
+// Unlike package eval the VM computes in real arithmetic, so operations
+// outside the real domain such as sqrt(-1) give NaN.
+package vm
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// opcode is a bytecode operation. Operations pop their operands
+// off the stack and push their result unless noted otherwise.
+type opcode uint8
+
+const (
+	opConst  opcode = iota // Push constant arg.
+	opX                    // Push element arg of X.
+	opU                    // Push element arg of U.
+	opLoad                 // Push register arg.
+	opStore                // Copy top of stack to register arg without popping.
+	opOut                  // Pop into element arg of out.
+	opNeg                  // -x
+	opSquare               // x*x
+	opAdd                  // x+y
+	opSub                  // x-y
+	opMul                  // x*y
+	opDiv                  // x/y
+	opPow                  // x^y
+	opCall1                // f(x) with f of funcs1[arg].
+	opCall2                // f(x,y) with f of funcs2[arg].
+)
+
+var opNames = [...]string{
+	opConst:  "const",
+	opX:      "x",
+	opU:      "u",
+	opLoad:   "load",
+	opStore:  "store",
+	opOut:    "out",
+	opNeg:    "neg",
+	opSquare: "square",
+	opAdd:    "add",
+	opSub:    "sub",
+	opMul:    "mul",
+	opDiv:    "div",
+	opPow:    "pow",
+	opCall1:  "call",
+	opCall2:  "call",
+}
+
+func (op opcode) String() string { return opNames[op] }
+
+// stackEffect returns the change of the stack depth after op.
+func (op opcode) stackEffect() int {
+	switch op {
+	case opConst, opX, opU, opLoad:
+		return 1
+	case opStore, opNeg, opSquare, opCall1:
+		return 0
+	}
+	return -1
+}
+
+type instr struct {
+	op  opcode
+	arg uint32
+}
+
+// Program is a compiled expression. Its memory is reused between
+// evaluations so it must not be evaluated concurrently, see [Program.Clone].
+type Program struct {
+	code         []instr
+	consts       []float64
+	mem          []float64 // Stack followed by registers.
+	nstack       int
+	nx, nu       int // Minimum lengths of X and U.
+	rows, cols   int
+	xname, uname string
+}
+
+// NumOut returns the number of elements of the expression's value.
+func (p *Program) NumOut() int { return p.rows * p.cols }
+
+// Dims returns the number of rows and columns of the expression's value.
+func (p *Program) Dims() (rows, cols int) { return p.rows, p.cols }
+
+// Len returns the number of instructions of p.
+func (p *Program) Len() int { return len(p.code) }
+
+// Clone returns a copy of p with its own memory so that
+// both can be evaluated concurrently.
+func (p *Program) Clone() *Program {
+	q := *p
+	q.mem = make([]float64, len(p.mem))
+	return &q
+}
+
+// Eval evaluates the expression with variables X and U and stores
+// its elements in column-major order in out, which must be of length [Program.NumOut].
+func (p *Program) Eval(X, U, out []float64) error {
+	if len(X) < p.nx {
+		return fmt.Errorf("%s has %d elements, expression indexes %d", p.xname, len(X), p.nx)
+	} else if len(U) < p.nu {
+		return fmt.Errorf("%s has %d elements, expression indexes %d", p.uname, len(U), p.nu)
+	} else if len(out) != p.NumOut() {
+		return fmt.Errorf("out has %d elements, want %d", len(out), p.NumOut())
+	}
+	stack, regs := p.mem[:p.nstack], p.mem[p.nstack:]
+	sp := 0
+	for _, in := range p.code {
+		switch in.op {
+		case opConst:
+			stack[sp] = p.consts[in.arg]
+			sp++
+		case opX:
+			stack[sp] = X[in.arg]
+			sp++
+		case opU:
+			stack[sp] = U[in.arg]
+			sp++
+		case opLoad:
+			stack[sp] = regs[in.arg]
+			sp++
+		case opStore:
+			regs[in.arg] = stack[sp-1]
+		case opOut:
+			sp--
+			out[in.arg] = stack[sp]
+		case opNeg:
+			stack[sp-1] = -stack[sp-1]
+		case opSquare:
+			stack[sp-1] *= stack[sp-1]
+		case opAdd:
+			sp--
+			stack[sp-1] += stack[sp]
+		case opSub:
+			sp--
+			stack[sp-1] -= stack[sp]
+		case opMul:
+			sp--
+			stack[sp-1] *= stack[sp]
+		case opDiv:
+			sp--
+			stack[sp-1] /= stack[sp]
+		case opPow:
+			sp--
+			stack[sp-1] = math.Pow(stack[sp-1], stack[sp])
+		case opCall1:
+			stack[sp-1] = funcs1[in.arg].f(stack[sp-1])
+		case opCall2:
+			sp--
+			stack[sp-1] = funcs2[in.arg].f(stack[sp-1], stack[sp])
+		}
+	}
+	return nil
+}
+
+// String returns the disassembled bytecode of p, one instruction per line.
+func (p *Program) String() string {
+	var b strings.Builder
+	for i, in := range p.code {
+		fmt.Fprintf(&b, "%4d  %-6s", i, in.op)
+		switch in.op {
+		case opConst:
+			fmt.Fprintf(&b, " %v", p.consts[in.arg])
+		case opX:
+			fmt.Fprintf(&b, " %s(%d)", p.xname, in.arg+1)
+		case opU:
+			fmt.Fprintf(&b, " %s(%d)", p.uname, in.arg+1)
+		case opLoad, opStore:
+			fmt.Fprintf(&b, " r%d", in.arg)
+		case opOut:
+			fmt.Fprintf(&b, " %d", in.arg+1)
+		case opCall1:
+			b.WriteString(" " + funcs1[in.arg].name)
+		case opCall2:
+			b.WriteString(" " + funcs2[in.arg].name)
+		}
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
@@ -0,0 +1,367 @@
+package vm
+
+import (
+	"bytes"
+	"errors"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"math"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/soypat/lexer/lexers/pike"
+	"github.com/soypat/lexer/lexers/pike/eval"
+	"github.com/soypat/lexer/lexers/pike/parse"
+)
+
+var (
+	testX = []float64{1, 2, 3, 0.1, -0.2, 0.3, 7, 8, 9, 10, 11, 12}
+	testU = []float64{1.5, 0.25, -0.5, 2}
+)
+
+func parseString(tb testing.TB, input string) parse.Expr {
+	tb.Helper()
+	l, err := pike.NewLexer("test.m", input, pike.Options{Variables: []string{"X", "U"}, Builtins: true})
+	if err != nil {
+		tb.Fatal(err)
+	}
+	x, err := parse.Parse(l.Items())
+	if err != nil {
+		tb.Fatalf("%q: %v", input, err)
+	}
+	return x
+}
+
+func compileString(tb testing.TB, input string) *Program {
+	tb.Helper()
+	p, err := Compile(parseString(tb, input), "X", "U")
+	if err != nil {
+		tb.Fatalf("%q: %v", input, err)
+	}
+	return p
+}
+
+// dynamics returns the state derivative of the example.
+func dynamics(tb testing.TB) parse.Expr {
+	tb.Helper()
+	b, err := os.ReadFile("testdata/dynamics.m")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return parseString(tb, strings.TrimSpace(string(b)))
+}
+
+// count returns the number of instructions of p with opcode op.
+func count(p *Program, op opcode) int {
+	n := 0
+	for _, in := range p.code {
+		if in.op == op {
+			n++
+		}
+	}
+	return n
+}
+
+func TestCompileDynamics(t *testing.T) {
+	x := dynamics(t)
+	p, err := Compile(x, "X", "U")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := eval.Eval(x, &eval.Env{Vars: map[string][]float64{"X": testX, "U": testU}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rows, cols := p.Dims(); rows != want.Rows || cols != want.Cols {
+		t.Fatalf("got %dx%d, want %dx%d", rows, cols, want.Rows, want.Cols)
+	}
+	got := make([]float64, p.NumOut())
+	if err := p.Eval(testX, testU, got); err != nil {
+		t.Fatal(err)
+	}
+	for i, z := range want.Data {
+		if math.Abs(got[i]-real(z)) > 1e-12*math.Max(1, math.Abs(real(z))) {
+			t.Errorf("element %d: got %v, want %v", i+1, got[i], real(z))
+		}
+	}
+	// Sines and cosines of X(4), X(5) and X(6), and sin(U(2)), sin(U(3)) and cos(U(3)+U(2)).
+	if n := count(p, opCall1); n != 9 {
+		t.Errorf("got %d calls, want 9 after eliminating common subexpressions", n)
+	}
+}
+
+func TestCompile(t *testing.T) {
+	tests := []struct {
+		input string
+		want  float64
+		calls int // Number of function calls after elimination of common subexpressions.
+	}{
+		{"cos(X(4))*2+cos(X(4))", 3 * math.Cos(0.1), 1},
+		{"sin(X(1)*X(2))-sin(X(2)*X(1))", 0, 1},
+		{"-979/100", -9.79, 0},
+		{"sqrt(2)^2*X(3)", math.Sqrt(2) * math.Sqrt(2) * 3, 0},
+		{"2^3^2", 64, 0},
+		{"2^-1^2", 0.25, 0},
+		{"-X(1)^2", -1, 0},
+		{"X(2)^-1", 0.5, 0},
+		{"U(2*2)+pi()", 2 + math.Pi, 0},
+		{"2*pi-X(1)", 2*math.Pi - 1, 0},
+		{"atan2(X(1),X(1))*4", math.Pi, 1},
+		{"mod(-7,X(3))+rem(-7,X(3))", 2 - 1, 2},
+		{"nthroot(-27,X(3))", -3, 1},
+		{"round(2.567,X(2))", 2.57, 1},
+		{"sqrt(-X(1))", math.NaN(), 1},
+	}
+	for _, test := range tests {
+		p := compileString(t, test.input)
+		var out [1]float64
+		if err := p.Eval(testX, testU, out[:]); err != nil {
+			t.Errorf("%q: %v", test.input, err)
+			continue
+		}
+		got := out[0]
+		if math.IsNaN(test.want) != math.IsNaN(got) || math.Abs(got-test.want) > 1e-15 {
+			t.Errorf("%q: got %v, want %v", test.input, got, test.want)
+		}
+		if calls := count(p, opCall1) + count(p, opCall2); calls != test.calls {
+			t.Errorf("%q: got %d calls, want %d\n%s", test.input, calls, test.calls, p)
+		}
+	}
+}
+
+func TestCompileMatrix(t *testing.T) {
+	p := compileString(t, "[X(1),2;3,X(1)*4;cos(X(1)),6]")
+	if rows, cols := p.Dims(); rows != 3 || cols != 2 {
+		t.Fatalf("got %dx%d, want 3x2", rows, cols)
+	}
+	out := make([]float64, 6)
+	if err := p.Eval(testX, testU, out); err != nil {
+		t.Fatal(err)
+	}
+	want := []float64{1, 3, math.Cos(1), 2, 4, 6}
+	for i := range want {
+		if out[i] != want[i] {
+			t.Errorf("got %v, want %v", out, want)
+			break
+		}
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		pos   int
+	}{
+		{"X(U(1))", 2},
+		{"X(0)", 2},
+		{"X(1,1)", 0},
+		{"X+1", 0},
+		{"2i*X(1)", 0},
+		{"X(1:3)", 2},
+		{"sum(X(1))", 0},
+		{"[X(1),X(2);X(3)]", 11},
+		{"1+[1,2]", 2},
+	}
+	for _, test := range tests {
+		_, err := Compile(parseString(t, test.input), "X", "U")
+		var cerr *Error
+		if !errors.As(err, &cerr) {
+			t.Errorf("%q: expected *Error, got %v", test.input, err)
+		} else if cerr.Pos != test.pos {
+			t.Errorf("%q: got error at %d, want %d: %v", test.input, cerr.Pos, test.pos, err)
+		}
+	}
+}
+
+func TestEvalLengths(t *testing.T) {
+	p := compileString(t, "[X(12);U(4)]")
+	out := make([]float64, 2)
+	if err := p.Eval(testX[:11], testU, out); err == nil {
+		t.Error("expected error for short X")
+	}
+	if err := p.Eval(testX, testU[:3], out); err == nil {
+		t.Error("expected error for short U")
+	}
+	if err := p.Eval(testX, testU, out[:1]); err == nil {
+		t.Error("expected error for short out")
+	}
+}
+
+func TestFuncsAgreeWithEval(t *testing.T) {
+	env := &eval.Env{Vars: map[string][]float64{"X": {0.3, 2}, "U": nil}}
+	for _, f := range funcs1 {
+		checkAgree(t, f.name+"(X(1))", env)
+	}
+	for _, f := range funcs2 {
+		checkAgree(t, f.name+"(X(1),X(2))", env)
+	}
+	for name := range constFuncs {
+		checkAgree(t, name+"()+X(1)", env)
+	}
+}
+
+func checkAgree(t *testing.T, input string, env *eval.Env) {
+	t.Helper()
+	x := parseString(t, input)
+	v, err := eval.Eval(x, env)
+	if err != nil {
+		t.Fatalf("%q: %v", input, err)
+	}
+	want := math.NaN() // Complex results of eval outside the real domain.
+	if v.IsReal() {
+		want, _ = v.Float()
+	}
+	p, err := Compile(x, "X", "U")
+	if err != nil {
+		t.Fatalf("%q: %v", input, err)
+	}
+	var out [1]float64
+	if err := p.Eval(env.Vars["X"], nil, out[:]); err != nil {
+		t.Fatal(err)
+	}
+	if out[0] != want && !(math.IsNaN(want) && math.IsNaN(out[0])) {
+		t.Errorf("%q: got %v, eval got %v", input, out[0], want)
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	cfg := GenConfig{Package: "model", Func: "Dynamics"}
+	src, err := cfg.Generate(compileString(t, "[X(7);cos(X(4))*sin(X(6))^2;-cos(X(4))/(2-U(1));X(1)^3;-979/100]"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"func Dynamics(X, U, out []float64) {",
+		"_ = X[6]",
+		"out[0] = X[6]\n",
+		"t0 := math.Cos(X[3])",
+		"t1 := math.Sin(X[5])",
+		"t0 * (t1 * t1)",
+		"-t0 / (2 - U[0])",
+		"math.Pow(X[0], 3)",
+		"out[4] = -9.79\n",
+	} {
+		if !bytes.Contains(src, []byte(want)) {
+			t.Errorf("generated source missing %q:\n%s", want, src)
+		}
+	}
+	typeCheck(t, src)
+
+	// Every function compiles to Go.
+	var input []byte
+	input = append(input, '[')
+	for _, f := range funcs1 {
+		input = append(input, f.name+"(X(1));"...)
+	}
+	for _, f := range funcs2 {
+		input = append(input, f.name+"(X(1),U(1));"...)
+	}
+	input = append(input, "pi()+X(1)]"...)
+	src, err = cfg.Generate(compileString(t, string(input)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	typeCheck(t, src)
+}
+
+func TestGenerateNames(t *testing.T) {
+	for _, test := range []struct {
+		cfg          GenConfig
+		xname, uname string
+	}{
+		{GenConfig{Package: "model"}, "out", "U"},
+		{GenConfig{Package: "model"}, "X", "t0"},
+		{GenConfig{Package: "model"}, "math", "U"},
+		{GenConfig{Package: "model"}, "X", "mod"},
+		{GenConfig{Package: "model"}, "X", "float64"},
+		{GenConfig{Package: "model", Func: "sec"}, "X", "U"},
+		{GenConfig{Package: "model", Func: "func"}, "X", "U"},
+		{GenConfig{Package: "model", Func: "init"}, "X", "U"},
+		{GenConfig{Package: "model", Func: "F-1"}, "X", "U"},
+		{GenConfig{Package: "my model"}, "X", "U"},
+	} {
+		l, err := pike.NewLexer("test.m", test.xname+"(1)+"+test.uname+"(1)", pike.Options{Variables: []string{test.xname, test.uname}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		x, err := parse.Parse(l.Items())
+		if err != nil {
+			t.Fatal(err)
+		}
+		p, err := Compile(x, test.xname, test.uname)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if src, err := test.cfg.Generate(p); err == nil {
+			t.Errorf("%+v with variables %s and %s: expected error, got\n%s", test.cfg, test.xname, test.uname, src)
+		}
+	}
+	// Names similar to those of generated code are allowed.
+	cfg := GenConfig{Package: "model", Func: "tx"}
+	src, err := cfg.Generate(compileString(t, "mod(X(1),U(1))"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	typeCheck(t, src)
+}
+
+func typeCheck(t *testing.T, src []byte) {
+	t.Helper()
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "gen.go", src, 0)
+	if err != nil {
+		t.Fatalf("%v:\n%s", err, src)
+	}
+	conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	if _, err := conf.Check("model", fset, []*ast.File{f}, nil); err != nil {
+		t.Errorf("%v:\n%s", err, src)
+	}
+}
+
+func TestEvalNoAlloc(t *testing.T) {
+	p, err := Compile(dynamics(t), "X", "U")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := make([]float64, p.NumOut())
+	allocs := testing.AllocsPerRun(100, func() {
+		p.Eval(testX, testU, out)
+	})
+	if allocs != 0 {
+		t.Errorf("got %v allocations per evaluation, want 0", allocs)
+	}
+}
+
+func BenchmarkEval(b *testing.B) {
+	p, err := Compile(dynamics(b), "X", "U")
+	if err != nil {
+		b.Fatal(err)
+	}
+	out := make([]float64, p.NumOut())
+	b.ReportAllocs()
+	for b.Loop() {
+		p.Eval(testX, testU, out)
+	}
+}
+
+// BenchmarkEvalTree evaluates the same expression by walking its tree with package eval.
+func BenchmarkEvalTree(b *testing.B) {
+	x := dynamics(b)
+	env := &eval.Env{Vars: map[string][]float64{"X": testX, "U": testU}}
+	b.ReportAllocs()
+	for b.Loop() {
+		eval.Eval(x, env)
+	}
+}
+
+func BenchmarkCompile(b *testing.B) {
+	x := dynamics(b)
+	b.ReportAllocs()
+	for b.Loop() {
+		Compile(x, "X", "U")
+	}
+}
@@ -0,0 +1,106 @@
+package phash
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/format"
+	"math/bits"
+	"strconv"
+)
+
+// Keyword is an entry of a generated keyword table.
+type Keyword struct {
+	Text  string // Keyword text hashed.
+	Value string // Go expression stored in the table for the keyword, i.e: a constant name.
+}
+
+// GenConfig configures the generation of Go source for a keyword hash function and its table.
+type GenConfig struct {
+	Package string // Package of the generated file.
+	Command string // Command recorded in the generated file header.
+	Func    string // Name of the hash function. Defaults to "kwhash".
+	Table   string // Name of the table variable. Defaults to "keywordMap".
+	Type    string // Type of the table elements, i.e: "Token".
+	// MaxTableBits limits the size of the table to 1<<MaxTableBits. Defaults to 10.
+	MaxTableBits int
+}
+
+// Generate finds a perfect hash for keywords and returns formatted Go source declaring
+// the hash function, its minimum input length constant (the function name suffixed with "MinLen")
+// and a table initialized with the keyword values at their hash index.
+// The table is checked to be free of collisions.
+func (cfg *GenConfig) Generate(keywords []Keyword) ([]byte, error) {
+	if cfg.Package == "" || cfg.Type == "" {
+		return nil, errors.New("package and type required")
+	}
+	fn, table, maxBits := cfg.Func, cfg.Table, cfg.MaxTableBits
+	if fn == "" {
+		fn = "kwhash"
+	}
+	if table == "" {
+		table = "keywordMap"
+	}
+	if maxBits == 0 {
+		maxBits = 10
+	}
+	texts := make([]string, len(keywords))
+	for i := range keywords {
+		texts[i] = keywords[i].Text
+	}
+	h, err := Find(texts, maxBits)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]*Keyword, h.TableSize())
+	for i := range keywords {
+		idx := h.Apply(keywords[i].Text)
+		if entries[idx] != nil {
+			return nil, fmt.Errorf("imperfect hash at %#x: %q collides with %q", idx, keywords[i].Text, entries[idx].Text)
+		}
+		entries[idx] = &keywords[i]
+	}
+
+	var b bytes.Buffer
+	if cfg.Command != "" {
+		fmt.Fprintf(&b, "// Code generated by %q; DO NOT EDIT.\n\n", cfg.Command)
+	} else {
+		b.WriteString("// Code generated by phash; DO NOT EDIT.\n\n")
+	}
+	fmt.Fprintf(&b, "package %s\n\n", cfg.Package)
+	fmt.Fprintf(&b, "// %sMinLen is the minimum length of input to %s.\n", fn, fn)
+	fmt.Fprintf(&b, "const %sMinLen = %d\n\n", fn, h.MinLen())
+	fmt.Fprintf(&b, "// %s is a perfect hash function for keywords.\n", fn)
+	fmt.Fprintf(&b, "// Input must be at least %sMinLen bytes long.\n", fn)
+	fmt.Fprintf(&b, "func %s(s string) uint {\n", fn)
+	lenCoef := h.Coefs[len(h.Coefs)-1]
+	fmt.Fprintf(&b, "\th := uint(len(s))%s\n", mulExpr(lenCoef.Value))
+	for _, c := range h.Coefs[:len(h.Coefs)-1] {
+		idx := strconv.Itoa(c.IndexApplied)
+		if c.IndexApplied < 0 {
+			idx = "len(s)" + idx
+		}
+		fmt.Fprintf(&b, "\th %s= uint(s[%s])%s\n", c.Op, idx, mulExpr(c.Value))
+	}
+	fmt.Fprintf(&b, "\treturn h & %d\n}\n\n", h.TableSize()-1)
+	fmt.Fprintf(&b, "// %s holds keywords at the index given by %s.\n", table, fn)
+	fmt.Fprintf(&b, "var %s = [%d]%s{\n", table, h.TableSize(), cfg.Type)
+	for idx, kw := range entries {
+		if kw != nil {
+			fmt.Fprintf(&b, "\t%d: %s, // %s\n", idx, kw.Value, kw.Text)
+		}
+	}
+	b.WriteString("}\n")
+	return format.Source(b.Bytes())
+}
+
+// mulExpr returns the Go expression multiplying by v, preferring bit shifts.
+func mulExpr(v uint) string {
+	switch {
+	case v == 1:
+		return ""
+	case bits.OnesCount(v) == 1:
+		return " << " + strconv.Itoa(bits.TrailingZeros(v))
+	}
+	return " * " + strconv.FormatUint(uint64(v), 10)
+}
@@ -0,0 +1,250 @@
+// Package phash searches for perfect hash functions over small sets of keywords
+// and generates Go source for them. The hash functions found are cheap enough
+// to be used by lexers for keyword lookup:
+//
+//	h := uint(len(s))*c2 + uint(s[0])*c0 ^ uint(s[1])*c1 ...
+//
+// Each [Coef] multiplies the byte at a fixed index of the keyword and combines
+// it into the hash, the last coefficient multiplies the keyword length.
+package phash
+
+import (
+	"errors"
+	"slices"
+)
+
+// Op is the operation combining a coefficient term into the hash.
+type Op uint8
+
+const (
+	OpAdd Op = iota // +
+	OpXor           // ^
+	OpMul           // *
+)
+
+func (op Op) String() string {
+	switch op {
+	case OpAdd:
+		return "+"
+	case OpXor:
+		return "^"
+	case OpMul:
+		return "*"
+	}
+	return "Op(?)"
+}
+
+// Finder searches for perfect hash coefficients.
+type Finder struct {
+	TableSizeBits  int
+	DefaultMaxCoef uint
+	hashmap        []uint
+}
+
+// Coef is a coefficient of the hash function.
+type Coef struct {
+	IndexApplied int  // Index at which hash consumes byte. Negative value indexes from the end.
+	Value        uint // Coefficient value to multiply byte at index.
+	MaxValue     uint
+	StartValue   uint
+	OnlyPow2     bool
+	Op           Op
+}
+
+var ErrNoCoefficientsFound = errors.New("no coefficients found")
+
+func (c *Coef) init() {
+	if c.StartValue == 0 {
+		c.Value = 1
+	} else {
+		c.Value = c.StartValue
+	}
+}
+func (c *Coef) increment() {
+	if c.OnlyPow2 {
+		c.Value *= 2
+	} else {
+		c.Value++
+	}
+}
+func (c *Coef) saturated() bool { return c.Value >= c.MaxValue }
+
+// Search iterates over coefficient values until a hash without collisions over inputs is found,
+// in which case the coefficient values are left set in coefs. It returns the number of attempts.
+func (phf *Finder) Search(coefs []Coef, inputs []string) (int, error) {
+	if phf.TableSizeBits <= 0 || phf.TableSizeBits > 32 {
+		return 0, errors.New("zero/negative bits for table size or too large")
+	} else if len(coefs) == 0 {
+		return 0, errors.New("require at least one coefficient to find perfect hash")
+	} else if len(inputs) == 0 {
+		return 0, errors.New("zero inputs")
+	}
+	err := phf.ConfigureCoefsWithDefaults(coefs)
+	if err != nil {
+		return 0, err
+	}
+	tblsz := 1 << phf.TableSizeBits
+	phf.hashmap = slices.Grow(phf.hashmap[:0], tblsz)[:tblsz]
+	hashmap := phf.hashmap
+	mask := uint(tblsz) - 1
+	currentAttempt := 0
+	for {
+		currentAttempt++
+		attemptSuccess := true
+		clear(hashmap)
+		for _, kw := range inputs {
+			h := apply(mask, coefs, kw)
+			tok := hashmap[h]
+			if tok != 0 {
+				attemptSuccess = false
+				break
+			}
+			hashmap[h] = 1
+		}
+		if attemptSuccess {
+			return currentAttempt, nil
+		}
+		coefs[0].increment()
+		for i := 0; coefs[i].saturated() && i < len(coefs)-1; i++ {
+			coefs[i].init()
+			coefs[i+1].increment()
+		}
+		// Check for super-saturation.
+		if coefs[len(coefs)-1].Value > coefs[len(coefs)-1].MaxValue {
+			break
+		}
+	}
+	return currentAttempt, ErrNoCoefficientsFound
+}
+
+// ConfigureCoefsWithDefaults sets coefficients to their start value and
+// their maximum value to DefaultMaxCoef if not set.
+func (phf *Finder) ConfigureCoefsWithDefaults(coefs []Coef) error {
+	for i := range coefs {
+		coefs[i].init()
+		if coefs[i].MaxValue == 0 {
+			if phf.DefaultMaxCoef <= 0 {
+				return errors.New("default max coefficient need be set and positive for input")
+			}
+			coefs[i].MaxValue = phf.DefaultMaxCoef
+		}
+	}
+	return nil
+}
+
+// Apply returns the hash of kw with the current coefficient values.
+func (phf *Finder) Apply(coefs []Coef, kw string) uint {
+	h := apply((1<<phf.TableSizeBits)-1, coefs, kw)
+	return h
+}
+
+func apply(mask uint, coefs []Coef, kw string) uint {
+	h := uint(len(kw)) * coefs[len(coefs)-1].Value
+	for i := 0; i < len(coefs)-1; i++ {
+		idx := coefs[i].IndexApplied
+		var a uint
+		if idx < 0 && -idx <= len(kw) {
+			a = uint(kw[len(kw)+idx]) * coefs[i].Value
+		} else if idx >= 0 && idx < len(kw) {
+			a = uint(kw[idx]) * coefs[i].Value
+		}
+		switch coefs[i].Op {
+		case OpAdd:
+			h += a
+		case OpXor:
+			h ^= a
+		case OpMul:
+			h *= a
+		default:
+			panic("unsupported operation")
+		}
+
+	}
+	return h & mask
+}
+
+// Hash is a perfect hash function over a set of keywords.
+type Hash struct {
+	TableSizeBits int
+	// Coefs of the hash. The last coefficient multiplies the keyword length.
+	Coefs []Coef
+}
+
+// Apply returns the hash of kw, which is an index into a table of size 1<<TableSizeBits.
+func (h *Hash) Apply(kw string) uint {
+	return apply((1<<h.TableSizeBits)-1, h.Coefs, kw)
+}
+
+// TableSize returns the length of the table indexed by the hash.
+func (h *Hash) TableSize() int { return 1 << h.TableSizeBits }
+
+// MinLen returns the minimum input length for which all bytes consumed by the hash are in bounds.
+func (h *Hash) MinLen() int {
+	minLen := 0
+	for _, c := range h.Coefs[:len(h.Coefs)-1] {
+		need := c.IndexApplied + 1
+		if c.IndexApplied < 0 {
+			need = -c.IndexApplied
+		}
+		minLen = max(minLen, need)
+	}
+	return minLen
+}
+
+// layouts are the byte indices consumed by candidate hash functions tried by Find, simplest first.
+// This method of searching for a perfect hash is quite robust
+// and works even with Fortran keywords (70+ keywords, ~20 sharing identical END start).
+var layouts = [][]int{
+	{0, 1},
+	{0, -1},
+	{0, 1, -1},
+	{0, 1, 2},
+}
+
+// Find searches for a perfect hash over keywords using power of two coefficients
+// so that multiplications become bit shifts. Smaller tables and hash functions consuming
+// fewer bytes are preferred. maxTableBits limits the size of the table searched.
+func Find(keywords []string, maxTableBits int) (Hash, error) {
+	const maxCoef = 32
+	if len(keywords) == 0 {
+		return Hash{}, errors.New("no keywords")
+	}
+	minLen := len(keywords[0])
+	for i, kw := range keywords {
+		minLen = min(minLen, len(kw))
+		if slices.Contains(keywords[:i], kw) {
+			return Hash{}, errors.New("duplicate keyword " + kw)
+		}
+	}
+	minBits := 1
+	for 1<<minBits < len(keywords) {
+		minBits++
+	}
+	phf := Finder{DefaultMaxCoef: maxCoef}
+	for bits := minBits; bits <= maxTableBits; bits++ {
+		phf.TableSizeBits = bits
+		for _, layout := range layouts {
+			coefs := make([]Coef, len(layout)+1)
+			for i := range coefs {
+				coefs[i].OnlyPow2 = true // Beware: Is quite limiting when one has lots of keywords, but has performance benefits.
+				if i < len(layout) {
+					coefs[i].IndexApplied = layout[i]
+				}
+				if i%2 == 1 {
+					coefs[i].Op = OpXor
+				}
+			}
+			h := Hash{TableSizeBits: bits, Coefs: coefs}
+			if h.MinLen() > minLen {
+				continue // Hash would index out of bounds for shortest keyword.
+			}
+			_, err := phf.Search(coefs, keywords)
+			if err == nil {
+				return h, nil
+			} else if err != ErrNoCoefficientsFound {
+				return Hash{}, err
+			}
+		}
+	}
+	return Hash{}, ErrNoCoefficientsFound
+}
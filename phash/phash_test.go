@@ -0,0 +1,85 @@
+package phash
+
+import (
+	"bytes"
+	"testing"
+)
+
+var goKeywords = []string{
+	"break", "case", "chan", "const", "continue", "default", "defer", "else",
+	"fallthrough", "for", "func", "go", "goto", "if", "import", "interface",
+	"map", "package", "range", "return", "select", "struct", "switch", "type", "var",
+}
+
+// TestFinderSearch searches for a perfect hash function for Go keywords.
+// This test is normally skipped unless -run=TestFinderSearch is specified.
+func TestFinderSearch(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping hash search in short mode")
+	}
+	t.Logf("Searching perfect hash for %d keywords", len(goKeywords))
+	attempts := 0
+	tableSizes := []int{5, 6, 7, 8, 9, 10}
+	// Coefficients for perfect hash function.
+	// Last coefficient is length multiplication.
+	const maxCoef = 32
+	coefs := make([]Coef, 3)
+	for i := range len(coefs) {
+		coefs[i].IndexApplied = i
+		coefs[i].Op = OpAdd
+		coefs[i].OnlyPow2 = true
+	}
+	phf := Finder{
+		DefaultMaxCoef: maxCoef,
+	}
+	for _, tableSize := range tableSizes {
+		phf.TableSizeBits = tableSize
+		t.Logf("Trying table size %d...", 1<<tableSize)
+		currentAttempt, err := phf.Search(coefs, goKeywords)
+		attempts += currentAttempt
+		if err != nil && err != ErrNoCoefficientsFound {
+			t.Fatal(err)
+		} else if err == nil {
+			t.Logf("FOUND with tableSize=%d after %d attempts! (%d total attempts)", tableSize, currentAttempt, attempts)
+			for i := range coefs {
+				t.Logf("coef%d=%d op=%s", i, coefs[i].Value, coefs[i].Op.String())
+			}
+			return
+		}
+	}
+	t.Error("No perfect hash found after", attempts, "attempts")
+}
+
+func TestGenerate(t *testing.T) {
+	h, err := Find(goKeywords, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := make(map[uint]string)
+	for _, kw := range goKeywords {
+		idx := h.Apply(kw)
+		if other, ok := seen[idx]; ok {
+			t.Fatalf("%q collides with %q at %d", kw, other, idx)
+		}
+		seen[idx] = kw
+	}
+
+	var kws []Keyword
+	for _, kw := range goKeywords {
+		kws = append(kws, Keyword{Text: kw, Value: "Tok" + kw})
+	}
+	cfg := GenConfig{Package: "golang", Type: "Token"}
+	src, err := cfg.Generate(kws)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"func kwhash(s string) uint {", "const kwhashMinLen = ", "var keywordMap = [", "Tokfallthrough, // fallthrough"} {
+		if !bytes.Contains(src, []byte(want)) {
+			t.Errorf("generated source missing %q:\n%s", want, src)
+		}
+	}
+	_, err = cfg.Generate(append(kws, Keyword{Text: "if", Value: "TokIf2"}))
+	if err == nil {
+		t.Error("expected error for duplicate keyword")
+	}
+}